@@ -0,0 +1,93 @@
+package main
+
+import (
+	"dailyact/config"
+	"dailyact/models"
+	"log"
+
+	"github.com/joho/godotenv"
+	"gorm.io/gorm"
+)
+
+// Builds the blind search index for existing activities. Safe to run again,
+// the tokens of each activity are replaced.
+func main() {
+	// Load environment variables
+	if err := godotenv.Load(); err != nil {
+		log.Printf("Warning: .env file not found: %v", err)
+	}
+
+	// Initialize encryption and blind index services
+	encryptionService, err := models.NewEncryptionService()
+	if err != nil {
+		log.Fatalf("Failed to initialize encryption service: %v", err)
+	}
+
+	blindIndexService, err := models.NewBlindIndexService()
+	if err != nil {
+		log.Fatalf("Failed to initialize blind index service: %v", err)
+	}
+
+	// Connect to database
+	db := config.InitDB()
+
+	// Read the raw ciphertext, the values are decrypted here and only their tokens are written
+	type ActivityData struct {
+		ID          uint
+		Description string
+		Notes       string
+	}
+
+	successCount := 0
+	errorCount := 0
+
+	var batch []ActivityData
+	result := db.Table("activities").Select("id, description, notes").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for _, activity := range batch {
+			description, err := encryptionService.Decrypt(activity.Description)
+			if err != nil {
+				log.Printf("Error decrypting description for activity %d: %v", activity.ID, err)
+				errorCount++
+				continue
+			}
+
+			notes, err := encryptionService.Decrypt(activity.Notes)
+			if err != nil {
+				log.Printf("Error decrypting notes for activity %d: %v", activity.ID, err)
+				errorCount++
+				continue
+			}
+
+			tokens := blindIndexService.Tokens(description, notes)
+			err = db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Where("activity_id = ?", activity.ID).Delete(&models.ActivitySearchToken{}).Error; err != nil {
+					return err
+				}
+				for _, token := range tokens {
+					if err := tx.Create(&models.ActivitySearchToken{ActivityID: activity.ID, Token: token}).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				log.Printf("Failed to index activity %d: %v", activity.ID, err)
+				errorCount++
+				continue
+			}
+
+			successCount++
+		}
+
+		log.Printf("Progress: %d activities indexed", successCount)
+		return nil
+	})
+	if result.Error != nil {
+		log.Fatalf("Failed to fetch activities: %v", result.Error)
+	}
+
+	log.Printf("\n=========== INDEXING COMPLETE ===========")
+	log.Printf("Successfully indexed: %d", successCount)
+	log.Printf("Failed to index: %d", errorCount)
+	log.Printf("=========================================")
+}
@@ -32,11 +32,16 @@ func InitDB() *gorm.DB {
 	}
 
 	// Auto-migrate the schema
-	err = db.AutoMigrate(&models.Category{}, &models.Activity{}, &models.AppFeedback{})
+	err = db.AutoMigrate(&models.Category{}, &models.Activity{}, &models.AppFeedback{}, &models.Goal{}, &models.Tag{}, &models.ActivitySearchToken{}, &models.ActivityRevision{}, &models.Attachment{}, &models.CheckIn{}, &models.CalendarFeed{}, &models.RecurringTemplate{}, &models.ActivityTemplate{})
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// end_time became nullable for running activities, AutoMigrate does not drop NOT NULL on its own
+	if err := db.Exec("ALTER TABLE activities ALTER COLUMN end_time DROP NOT NULL").Error; err != nil {
+		log.Fatal("Failed to migrate database:", err)
+	}
+
 	return db
 }
 
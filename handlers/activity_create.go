@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"dailyact/models"
+
+	"gorm.io/gorm"
+)
+
+// insertActivity encrypts the plaintext description and notes into the activity, applies
+// the overlap policy and stores the activity with its search tokens and tags. It is the
+// creation path shared by every endpoint that creates activities; the caller must hold
+// the user lock (see lockUser).
+func (h *Handler) insertActivity(tx *gorm.DB, activity *models.Activity, description, notes string, tagNames []string, policy string) error {
+	descriptionEncrypted, err := h.encryptionService.Encrypt(description)
+	if err != nil {
+		return err
+	}
+	notesEncrypted, err := h.encryptionService.Encrypt(notes)
+	if err != nil {
+		return err
+	}
+	activity.Description = models.EncryptedString(descriptionEncrypted)
+	activity.Notes = models.EncryptedString(notesEncrypted)
+
+	if err := h.resolveOverlaps(tx, activity.UserID, 0, activity.StartTime, activity.EndTime, policy); err != nil {
+		return err
+	}
+	if err := tx.Create(activity).Error; err != nil {
+		return err
+	}
+	if err := h.indexActivity(tx, activity.ID, description, notes); err != nil {
+		return err
+	}
+
+	// The tags are set after Create so that GORM does not save the association itself
+	activity.Tags = []models.Tag{}
+	if len(tagNames) == 0 {
+		return nil
+	}
+	tags, err := findOrCreateTags(tx, activity.UserID, tagNames)
+	if err != nil {
+		return err
+	}
+	activity.Tags = tags
+	return setActivityTags(tx, activity.ID, tags)
+}
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/types"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+)
+
+// isUniqueViolation reports whether err is a PostgreSQL unique_violation (23505)
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// templateExistsResponse is the response to a template name the user already has
+func templateExistsResponse() types.Response {
+	return types.NewErrorResponse(
+		"TEMPLATE_EXISTS",
+		"Template already exists",
+		"A template with this name already exists",
+	)
+}
+
+// activityTemplateNameTaken reports whether the user has another template with the name
+func (h *Handler) activityTemplateNameTaken(userID uint, name string, excludeID uint) (bool, error) {
+	var existing int64
+	err := h.db.Model(&models.ActivityTemplate{}).
+		Where("user_id = ? AND LOWER(name) = LOWER(?) AND id <> ?", userID, name, excludeID).
+		Count(&existing).Error
+	return existing > 0, err
+}
+
+// bindActivityTemplate reads and checks a template request, writing the error response when it is invalid
+func (h *Handler) bindActivityTemplate(c *gin.Context, userID, excludeID uint) (models.ActivityTemplateRequest, bool) {
+	var input models.ActivityTemplateRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return input, false
+	}
+	input.Name = strings.TrimSpace(input.Name)
+	if input.Name == "" {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			"name cannot be empty",
+		))
+		return input, false
+	}
+
+	if err := h.db.First(&models.Category{}, input.CategoryID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Category not found",
+			err.Error(),
+		))
+		return input, false
+	}
+
+	taken, err := h.activityTemplateNameTaken(userID, input.Name, excludeID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to check template name",
+			err.Error(),
+		))
+		return input, false
+	}
+	if taken {
+		c.JSON(http.StatusConflict, templateExistsResponse())
+		return input, false
+	}
+	return input, true
+}
+
+// encryptActivityTemplate stores the request in the template, encrypting description and notes
+func (h *Handler) encryptActivityTemplate(c *gin.Context, template *models.ActivityTemplate, input models.ActivityTemplateRequest) bool {
+	descriptionEncrypted, err := h.encryptionService.Encrypt(input.Description)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"ENCRYPTION_ERROR",
+			"Failed to encrypt description",
+			err.Error(),
+		))
+		return false
+	}
+	notesEncrypted, err := h.encryptionService.Encrypt(input.Notes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"ENCRYPTION_ERROR",
+			"Failed to encrypt notes",
+			err.Error(),
+		))
+		return false
+	}
+
+	template.Name = input.Name
+	template.CategoryID = input.CategoryID
+	template.Description = models.EncryptedString(descriptionEncrypted)
+	template.Notes = models.EncryptedString(notesEncrypted)
+	template.DefaultDuration = input.DefaultDuration
+	return true
+}
+
+func (h *Handler) CreateActivityTemplate(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	input, ok := h.bindActivityTemplate(c, user.ID, 0)
+	if !ok {
+		return
+	}
+
+	template := models.ActivityTemplate{UserID: user.ID}
+	if !h.encryptActivityTemplate(c, &template, input) {
+		return
+	}
+	if err := h.db.Create(&template).Error; err != nil {
+		// A template of the same name may have been created since the name was checked
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, templateExistsResponse())
+			return
+		}
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to create template",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.Preload("Category").First(&template, template.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to reload template data",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusCreated, types.NewSuccessResponse(
+		"Template created successfully",
+		template,
+		nil,
+	))
+}
+
+// GetActivityTemplates lists the current user's templates by name
+func (h *Handler) GetActivityTemplates(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var templates []models.ActivityTemplate
+	if err := h.db.Preload("Category").Where("user_id = ?", user.ID).Order("name ASC").Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch templates",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Templates retrieved successfully",
+		templates,
+		nil,
+	))
+}
+
+func (h *Handler) GetActivityTemplateByID(c *gin.Context) {
+	var template models.ActivityTemplate
+	if err := h.db.Preload("Category").First(&template, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Template not found",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Template retrieved successfully",
+		template,
+		nil,
+	))
+}
+
+func (h *Handler) UpdateActivityTemplate(c *gin.Context) {
+	var template models.ActivityTemplate
+	if err := h.db.First(&template, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Template not found",
+			err.Error(),
+		))
+		return
+	}
+
+	input, ok := h.bindActivityTemplate(c, template.UserID, template.ID)
+	if !ok {
+		return
+	}
+	if !h.encryptActivityTemplate(c, &template, input) {
+		return
+	}
+
+	if err := h.db.Save(&template).Error; err != nil {
+		if isUniqueViolation(err) {
+			c.JSON(http.StatusConflict, templateExistsResponse())
+			return
+		}
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to update template",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.Preload("Category").First(&template, template.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to reload template data",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Template updated successfully",
+		template,
+		nil,
+	))
+}
+
+func (h *Handler) DeleteActivityTemplate(c *gin.Context) {
+	var template models.ActivityTemplate
+	if err := h.db.First(&template, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Template not found",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.Delete(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to delete template",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Template deleted successfully",
+		nil,
+		nil,
+	))
+}
+
+// CreateActivityFromTemplate logs an activity from a template. The activity lasts the
+// template's default duration and starts at start_time, by default so that it ends now.
+func (h *Handler) CreateActivityFromTemplate(c *gin.Context) {
+	var input struct {
+		StartTime *time.Time `json:"start_time"` // defaults to now minus the default duration
+	}
+
+	// The body is optional, an empty request logs the activity as just finished. A chunked
+	// request has no content length, so an empty body is told apart by reading it.
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+
+	var overlapQuery types.OverlapQuery
+	if err := c.ShouldBindQuery(&overlapQuery); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid overlap policy",
+			err.Error(),
+		))
+		return
+	}
+
+	var template models.ActivityTemplate
+	if err := h.db.First(&template, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Template not found",
+			err.Error(),
+		))
+		return
+	}
+
+	duration := time.Duration(template.DefaultDuration) * time.Second
+	startTime := time.Now().Add(-duration)
+	if input.StartTime != nil {
+		startTime = *input.StartTime
+	}
+	endTime := startTime.Add(duration)
+
+	// The activity belongs to the template's owner, also when an admin uses the template
+	activity := models.Activity{
+		StartTime:  startTime,
+		EndTime:    &endTime,
+		CategoryID: template.CategoryID,
+		UserID:     template.UserID,
+	}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockUser(tx, activity.UserID); err != nil {
+			return err
+		}
+		return h.insertActivity(tx, &activity, template.Description.String(), template.Notes.String(), nil, overlapQuery.Overlap)
+	})
+	if err != nil {
+		respondSaveError(c, err, "Failed to create activity")
+		return
+	}
+
+	if err := h.db.Preload("Category").Preload("Tags").First(&activity, activity.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to reload activity data",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusCreated, types.NewSuccessResponse(
+		"Activity created successfully",
+		activity,
+		nil,
+	))
+}
@@ -0,0 +1,297 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"dailyact/models"
+	"dailyact/types"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAttachmentSize is the largest file accepted as an attachment, in bytes
+const maxAttachmentSize = 10 << 20
+
+// allowedAttachmentTypes are the content types accepted for attachments. The type is
+// detected from the content, the one sent by the client is not trusted.
+var allowedAttachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+// newStorageKey returns a random key for a stored object
+func newStorageKey() (string, error) {
+	key := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(key), nil
+}
+
+// deleteStoredAttachments removes attachment contents whose rows are already deleted.
+// Failures only leave unreachable encrypted files behind, so they are logged.
+func (h *Handler) deleteStoredAttachments(keys []string) {
+	for _, key := range keys {
+		if err := h.attachmentStorage.Delete(key); err != nil {
+			log.Println("Failed to delete attachment content:", err)
+		}
+	}
+}
+
+// findAttachment loads the attachment of the id parameters, making sure it belongs to the activity
+func (h *Handler) findAttachment(c *gin.Context) (*models.Attachment, bool) {
+	var attachment models.Attachment
+	if err := h.db.Where("activity_id = ?", c.Param("id")).First(&attachment, c.Param("attachment_id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Attachment not found",
+			err.Error(),
+		))
+		return nil, false
+	}
+	return &attachment, true
+}
+
+// UploadAttachment stores the multipart file field "file" encrypted and attaches it to the activity
+func (h *Handler) UploadAttachment(c *gin.Context) {
+	var activity models.Activity
+	if err := h.db.First(&activity, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Activity not found",
+			err.Error(),
+		))
+		return
+	}
+
+	// Leave room for the multipart headers around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxAttachmentSize+64<<10)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			c.JSON(http.StatusRequestEntityTooLarge, types.NewErrorResponse(
+				"ATTACHMENT_TOO_LARGE",
+				"Attachment is too large",
+				"Attachments can be at most "+strconv.Itoa(maxAttachmentSize>>20)+" MB",
+			))
+			return
+		}
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+
+	if header.Size > maxAttachmentSize {
+		c.JSON(http.StatusRequestEntityTooLarge, types.NewErrorResponse(
+			"ATTACHMENT_TOO_LARGE",
+			"Attachment is too large",
+			"Attachments can be at most "+strconv.Itoa(maxAttachmentSize>>20)+" MB",
+		))
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Failed to read attachment",
+			err.Error(),
+		))
+		return
+	}
+	defer file.Close()
+
+	// Detect the content type from the first bytes of the file
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Failed to read attachment",
+			err.Error(),
+		))
+		return
+	}
+	head = head[:n]
+
+	contentType, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil || !allowedAttachmentTypes[contentType] {
+		c.JSON(http.StatusUnsupportedMediaType, types.NewErrorResponse(
+			"UNSUPPORTED_MEDIA_TYPE",
+			"Attachment type is not supported",
+			"Attachments must be JPEG, PNG, GIF or WebP images or PDF documents",
+		))
+		return
+	}
+
+	key, err := newStorageKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"STORAGE_ERROR",
+			"Failed to store attachment",
+			err.Error(),
+		))
+		return
+	}
+
+	writer, err := h.attachmentStorage.Create(key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"STORAGE_ERROR",
+			"Failed to store attachment",
+			err.Error(),
+		))
+		return
+	}
+	err = h.fileEncryptionService.Encrypt(writer, io.MultiReader(bytes.NewReader(head), file))
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		h.deleteStoredAttachments([]string{key})
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"ENCRYPTION_ERROR",
+			"Failed to encrypt attachment",
+			err.Error(),
+		))
+		return
+	}
+
+	fileNameEncrypted, err := h.encryptionService.Encrypt(filepath.Base(header.Filename))
+	if err != nil {
+		h.deleteStoredAttachments([]string{key})
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"ENCRYPTION_ERROR",
+			"Failed to encrypt file name",
+			err.Error(),
+		))
+		return
+	}
+
+	attachment := models.Attachment{
+		ActivityID:  activity.ID,
+		UserID:      activity.UserID,
+		FileName:    models.EncryptedString(fileNameEncrypted),
+		ContentType: contentType,
+		Size:        header.Size,
+		StorageKey:  key,
+	}
+	if err := h.db.Create(&attachment).Error; err != nil {
+		h.deleteStoredAttachments([]string{key})
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to create attachment",
+			err.Error(),
+		))
+		return
+	}
+
+	// Respond with the plaintext file name rather than the stored ciphertext
+	attachment.FileName = models.EncryptedString(filepath.Base(header.Filename))
+	c.JSON(http.StatusCreated, types.NewSuccessResponse(
+		"Attachment uploaded successfully",
+		attachment,
+		nil,
+	))
+}
+
+// GetAttachments lists the attachments of an activity
+func (h *Handler) GetAttachments(c *gin.Context) {
+	var attachments []models.Attachment
+	if err := h.db.Where("activity_id = ?", c.Param("id")).Order("created_at").Find(&attachments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch attachments",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Attachments retrieved successfully",
+		attachments,
+		nil,
+	))
+}
+
+// DownloadAttachment streams the decrypted content of an attachment
+func (h *Handler) DownloadAttachment(c *gin.Context) {
+	attachment, ok := h.findAttachment(c)
+	if !ok {
+		return
+	}
+
+	reader, err := h.attachmentStorage.Open(attachment.StorageKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"STORAGE_ERROR",
+			"Failed to read attachment",
+			err.Error(),
+		))
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Content-Type", attachment.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": attachment.FileName.String(),
+	}))
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Status(http.StatusOK)
+
+	if err := h.fileEncryptionService.Decrypt(c.Writer, reader); err != nil {
+		// Once content went out the status cannot change anymore, the client sees a short body
+		if !c.Writer.Written() {
+			c.Writer.Header().Del("Content-Length")
+			c.Writer.Header().Del("Content-Disposition")
+			c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+				"ENCRYPTION_ERROR",
+				"Failed to decrypt attachment",
+				err.Error(),
+			))
+			return
+		}
+		log.Println("Failed to stream attachment:", err)
+	}
+}
+
+// DeleteAttachment removes an attachment and its stored content
+func (h *Handler) DeleteAttachment(c *gin.Context) {
+	attachment, ok := h.findAttachment(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(attachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to delete attachment",
+			err.Error(),
+		))
+		return
+	}
+	h.deleteStoredAttachments([]string{attachment.StorageKey})
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Attachment deleted successfully",
+		nil,
+		nil,
+	))
+}
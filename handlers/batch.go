@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/types"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"gorm.io/gorm"
+)
+
+// errBatchAborted rolls an atomic batch back after one of its operations failed
+var errBatchAborted = errors.New("batch aborted")
+
+// batchError is an operation of a batch refused with a response of its own
+type batchError struct {
+	status   int
+	response types.Response
+}
+
+func (e *batchError) Error() string {
+	return e.response.Error.Detail
+}
+
+// invalidBatchOperation refuses an operation with invalid input
+func invalidBatchOperation(detail string) *batchError {
+	return &batchError{http.StatusBadRequest, types.NewErrorResponse(
+		"INVALID_INPUT",
+		"Invalid input data",
+		detail,
+	)}
+}
+
+// lockBatchOwners takes the user lock of the user and, for an admin, of the owners of
+// the activities the operations refer to, in ID order so concurrent batches cannot deadlock
+func lockBatchOwners(tx *gorm.DB, user models.User, operations []types.BatchOperation) error {
+	owners := []uint{user.ID}
+	if user.Role == models.RoleAdmin {
+		var ids []uint
+		for _, op := range operations {
+			if op.ID != 0 {
+				ids = append(ids, op.ID)
+			}
+		}
+		if len(ids) > 0 {
+			var others []uint
+			if err := tx.Model(&models.Activity{}).Where("id IN ? AND user_id <> ?", ids, user.ID).Distinct().Pluck("user_id", &others).Error; err != nil {
+				return err
+			}
+			owners = append(owners, others...)
+		}
+	}
+
+	sort.Slice(owners, func(i, j int) bool { return owners[i] < owners[j] })
+	for _, owner := range owners {
+		if err := lockUser(tx, owner); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// batchActivity loads the activity an operation refers to, checking it the way
+// RequireOwnershipOrAdmin checks a single activity
+func batchActivity(tx *gorm.DB, user models.User, id uint) (models.Activity, error) {
+	var activity models.Activity
+	if id == 0 {
+		return activity, invalidBatchOperation("id is required")
+	}
+	if err := tx.First(&activity, id).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return activity, err
+		}
+		return activity, &batchError{http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Activity not found",
+			err.Error(),
+		)}
+	}
+	if user.Role != models.RoleAdmin && activity.OwnerID() != user.ID {
+		return activity, &batchError{http.StatusForbidden, types.NewErrorResponse(
+			"FORBIDDEN",
+			"Access denied",
+			"You don't have permission to access this activity",
+		)}
+	}
+	return activity, nil
+}
+
+// applyBatchOperation applies one operation the way the single activity endpoints do and
+// returns the ID of the activity and the status of the operation
+func (h *Handler) applyBatchOperation(tx *gorm.DB, user models.User, op types.BatchOperation, policy string) (uint, int, error) {
+	if op.Op == types.BatchDelete {
+		activity, err := batchActivity(tx, user, op.ID)
+		if err != nil {
+			return 0, 0, err
+		}
+		return activity.ID, http.StatusOK, trashActivity(tx, &activity)
+	}
+
+	if op.Op != types.BatchCreate && op.Op != types.BatchUpdate {
+		return 0, 0, invalidBatchOperation("op must be create, update or delete")
+	}
+	input := op.Activity
+	if input == nil {
+		return 0, 0, invalidBatchOperation("activity is required")
+	}
+	if err := binding.Validator.ValidateStruct(input); err != nil {
+		return 0, 0, invalidBatchOperation(err.Error())
+	}
+	var tagNames *[]string
+	if input.Tags != nil {
+		names, err := normalizeTagNames(*input.Tags)
+		if err != nil {
+			return 0, 0, invalidBatchOperation(err.Error())
+		}
+		tagNames = &names
+	}
+
+	if op.Op == types.BatchCreate {
+		if input.EndTime == nil {
+			return 0, 0, invalidBatchOperation("end_time is required")
+		}
+		activity := models.Activity{
+			StartTime:  input.StartTime,
+			EndTime:    input.EndTime,
+			Mood:       input.Mood,
+			Energy:     input.Energy,
+			CategoryID: input.CategoryID,
+			UserID:     user.ID,
+		}
+		var names []string
+		if tagNames != nil {
+			names = *tagNames
+		}
+		if err := h.insertActivity(tx, &activity, input.Description, input.Notes, names, policy); err != nil {
+			return 0, 0, err
+		}
+		return activity.ID, http.StatusCreated, nil
+	}
+
+	activity, err := batchActivity(tx, user, op.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if input.EndTime == nil && !activity.IsRunning {
+		return 0, 0, invalidBatchOperation("end_time is required for an activity that is not running")
+	}
+	activity.StartTime = input.StartTime
+	activity.EndTime = input.EndTime
+	activity.Mood = input.Mood
+	activity.Energy = input.Energy
+	activity.CategoryID = input.CategoryID
+	// Editing a pending activity from a recurring template confirms it
+	activity.Pending = false
+	return activity.ID, http.StatusOK, h.updateActivity(tx, &activity, input.Description, input.Notes, tagNames, policy, user.ID)
+}
+
+// BatchActivities applies a list of create, update and delete operations in order in one
+// transaction. Each operation gets the result it would have had on its own endpoint.
+// Failed operations are undone and the others kept, unless the batch is atomic, in which
+// case the first failure rolls back the whole batch.
+func (h *Handler) BatchActivities(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var input types.BatchRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+
+	var overlapQuery types.OverlapQuery
+	if err := c.ShouldBindQuery(&overlapQuery); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid overlap policy",
+			err.Error(),
+		))
+		return
+	}
+
+	result := types.BatchResult{Results: make([]types.BatchItemResult, len(input.Operations))}
+	failed := -1 // the operation that aborted an atomic batch
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockBatchOwners(tx, user, input.Operations); err != nil {
+			return err
+		}
+
+		for i, op := range input.Operations {
+			item := &result.Results[i]
+			item.Index, item.Op = i, op.Op
+
+			// Each operation runs in a savepoint so a failed one leaves no trace
+			var activityID uint
+			var status int
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				var err error
+				activityID, status, err = h.applyBatchOperation(tx, user, op, overlapQuery.Overlap)
+				return err
+			})
+			if err == nil {
+				item.Status, item.Applied, item.ActivityID = status, true, activityID
+				result.Applied++
+				continue
+			}
+
+			var refused *batchError
+			if errors.As(err, &refused) {
+				item.Status, item.Error = refused.status, refused.response.Error
+			} else {
+				var response types.Response
+				item.Status, response = saveErrorResponse(err, fmt.Sprintf("Failed to %s activity", op.Op))
+				item.Error = response.Error
+			}
+			item.ActivityID = op.ID
+			result.Failed++
+			if input.Atomic {
+				failed = i
+				return errBatchAborted
+			}
+		}
+		return nil
+	})
+	if err != nil && failed < 0 {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to apply batch",
+			err.Error(),
+		))
+		return
+	}
+
+	if failed < 0 {
+		c.JSON(http.StatusOK, types.NewSuccessResponse(
+			"Batch applied",
+			result,
+			nil,
+		))
+		return
+	}
+
+	// Nothing of an aborted batch is kept
+	result.RolledBack = true
+	result.Applied = 0
+	for i := range result.Results {
+		item := &result.Results[i]
+		switch {
+		case i < failed:
+			item.Applied = false
+			if input.Operations[i].Op == types.BatchCreate {
+				item.ActivityID = 0
+			}
+		case i > failed:
+			item.Index, item.Op, item.Status = i, input.Operations[i].Op, http.StatusFailedDependency
+			item.Error = &types.ErrorInfo{
+				Code:    "BATCH_ABORTED",
+				Message: "Not applied",
+				Detail:  fmt.Sprintf("Operation %d failed and the batch is atomic", failed),
+			}
+		}
+	}
+	failure := result.Results[failed]
+	c.JSON(failure.Status, types.NewErrorResponseWithMeta(
+		"BATCH_FAILED",
+		"Batch rolled back",
+		fmt.Sprintf("Operation %d failed: %s", failed, failure.Error.Message),
+		result,
+	))
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"dailyact/ical"
+	"dailyact/models"
+	"dailyact/types"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxCalendarEvents caps the number of events in one calendar feed response
+const maxCalendarEvents = 10000
+
+// calendarFeedURL returns the URL of the feed with the given token, on the host the request came in on
+func calendarFeedURL(c *gin.Context, token string) string {
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + "/calendar/feed.ics?token=" + url.QueryEscape(token)
+}
+
+// CreateCalendarFeed creates the current user's calendar feed. An existing feed is
+// replaced, so the previous URL stops working.
+func (h *Handler) CreateCalendarFeed(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	token, hash, err := models.NewCalendarFeedToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"TOKEN_ERROR",
+			"Failed to create feed token",
+			err.Error(),
+		))
+		return
+	}
+
+	feed := models.CalendarFeed{UserID: user.ID, TokenHash: hash, CreatedAt: time.Now()}
+	if err := h.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token_hash", "created_at"}),
+	}).Create(&feed).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to create calendar feed",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusCreated, types.NewSuccessResponse(
+		"Calendar feed created successfully",
+		types.CalendarFeedInfo{URL: calendarFeedURL(c, token), CreatedAt: feed.CreatedAt},
+		nil,
+	))
+}
+
+// GetCalendarFeed tells whether the current user has a calendar feed. The URL cannot be shown again.
+func (h *Handler) GetCalendarFeed(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var feed models.CalendarFeed
+	if err := h.db.Where("user_id = ?", user.ID).First(&feed).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Calendar feed not found",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Calendar feed retrieved successfully",
+		types.CalendarFeedInfo{CreatedAt: feed.CreatedAt},
+		nil,
+	))
+}
+
+// DeleteCalendarFeed revokes the current user's calendar feed
+func (h *Handler) DeleteCalendarFeed(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	result := h.db.Where("user_id = ?", user.ID).Delete(&models.CalendarFeed{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to delete calendar feed",
+			result.Error.Error(),
+		))
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Calendar feed not found",
+			"The user has no calendar feed",
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Calendar feed deleted successfully",
+		nil,
+		nil,
+	))
+}
+
+// GetCalendarFeedICS serves the activities of the feed's owner as an iCalendar file.
+// It is authenticated by the token query parameter rather than a Bearer token.
+func (h *Handler) GetCalendarFeedICS(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusUnauthorized, types.NewErrorResponse(
+			"UNAUTHORIZED",
+			"Feed token required",
+			"Use the URL returned when the feed was created",
+		))
+		return
+	}
+
+	var feed models.CalendarFeed
+	err := h.db.Preload("User").Where("token_hash = ?", models.HashCalendarFeedToken(token)).First(&feed).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Calendar feed not found",
+			"The feed does not exist or has been revoked",
+		))
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch calendar feed",
+			err.Error(),
+		))
+		return
+	}
+
+	var filter types.ActivityFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid filter parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	// Without dates the feed covers the last year, so calendar apps stay responsive
+	loc := feed.User.Location()
+	if filter.StartDate == nil && filter.EndDate == nil {
+		start := time.Now().In(loc).AddDate(-1, 0, 0).Format("2006-01-02")
+		filter.StartDate = &start
+	}
+
+	db := h.db.Model(&models.Activity{}).Preload("Category").Where("activities.user_id = ?", feed.UserID)
+	db, err = h.applyActivityFilter(db, filter, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid filter parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	var activities []models.Activity
+	if err := db.Order("activities.start_time").Limit(maxCalendarEvents).Find(&activities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch activities",
+			err.Error(),
+		))
+		return
+	}
+
+	now := time.Now()
+	calendar := ical.Calendar{
+		ProductID: "-//DailyAct//Activities//EN",
+		Name:      "DailyAct",
+		Events:    make([]ical.Event, len(activities)),
+	}
+	for i, activity := range activities {
+		// Running activities show up to now
+		end := now
+		if activity.EndTime != nil {
+			end = *activity.EndTime
+		}
+
+		summary := activity.Description.String()
+		if summary == "" {
+			summary = activity.Category.Name
+		}
+
+		calendar.Events[i] = ical.Event{
+			UID:         fmt.Sprintf("activity-%d@dailyact", activity.ID),
+			Stamp:       activity.UpdatedAt,
+			Start:       activity.StartTime,
+			End:         end,
+			Summary:     summary,
+			Description: activity.Notes.String(),
+			Categories:  []string{activity.Category.Name},
+		}
+	}
+
+	c.Header("Content-Type", "text/calendar; charset=utf-8")
+	c.Header("Content-Disposition", `inline; filename="dailyact.ics"`)
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Status(http.StatusOK)
+	if err := calendar.Write(c.Writer); err != nil {
+		log.Println("Failed to write calendar feed:", err)
+	}
+}
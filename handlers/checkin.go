@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/types"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+)
+
+// SaveCheckIn records the check-in of a day, replacing an earlier one for the same day
+func (h *Handler) SaveCheckIn(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var input models.CheckInRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+
+	date := models.LocalDate(time.Now(), user.Location())
+	if input.Date != nil {
+		day, err := types.ParseDay(*input.Date, time.UTC)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+				"INVALID_INPUT",
+				"Invalid input data",
+				"date must be formatted as YYYY-MM-DD",
+			))
+			return
+		}
+		date = day
+	}
+
+	checkIn := models.CheckIn{
+		UserID: user.ID,
+		Date:   date,
+		Mood:   input.Mood,
+		Energy: input.Energy,
+	}
+	if err := h.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "date"}},
+		DoUpdates: clause.AssignmentColumns([]string{"mood", "energy", "updated_at"}),
+	}).Create(&checkIn).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to save check-in",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.Where("user_id = ? AND date = ?", user.ID, date).First(&checkIn).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to reload check-in data",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Check-in saved successfully",
+		checkIn,
+		nil,
+	))
+}
+
+// GetCheckIns lists the current user's check-ins, newest day first
+func (h *Handler) GetCheckIns(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var query struct {
+		types.PaginationQuery
+		StartDate *string `form:"start_date"` // YYYY-MM-DD
+		EndDate   *string `form:"end_date"`   // YYYY-MM-DD
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid query parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	filter := types.ActivityFilter{StartDate: query.StartDate, EndDate: query.EndDate}
+	start, end, err := filterDateRange(filter, time.UTC)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid query parameters",
+			"start_date and end_date must be formatted as YYYY-MM-DD",
+		))
+		return
+	}
+
+	db := h.db.Model(&models.CheckIn{}).Where("user_id = ?", user.ID)
+	if start != nil {
+		db = db.Where("date >= ?", *start)
+	}
+	if end != nil {
+		db = db.Where("date < ?", *end)
+	}
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to count check-ins",
+			err.Error(),
+		))
+		return
+	}
+
+	var checkIns []models.CheckIn
+	offset := (query.Page - 1) * query.PageSize
+	if err := db.Order("date DESC").Offset(offset).Limit(query.PageSize).Find(&checkIns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch check-ins",
+			err.Error(),
+		))
+		return
+	}
+
+	pagination := types.NewPaginationResponse(query.Page, query.PageSize, total)
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Check-ins retrieved successfully",
+		checkIns,
+		&pagination,
+	))
+}
+
+func (h *Handler) DeleteCheckIn(c *gin.Context) {
+	var checkIn models.CheckIn
+	if err := h.db.First(&checkIn, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Check-in not found",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.Delete(&checkIn).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to delete check-in",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Check-in deleted successfully",
+		nil,
+		nil,
+	))
+}
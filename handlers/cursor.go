@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/types"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// activityCursor is the position after the last activity of a page. It records the
+// sort it was created for, a cursor cannot be reused with another sort.
+type activityCursor struct {
+	Sort  string          `json:"s"`
+	Order string          `json:"o"`
+	Value json.RawMessage `json:"v"`
+	ID    uint            `json:"id"`
+}
+
+// getActivitiesByCursor writes one page of activities ordered by (sort key, id).
+// Rows inserted between page loads cannot shift the following pages.
+func (h *Handler) getActivitiesByCursor(c *gin.Context, db *gorm.DB, pageSize int, query types.CursorQuery, sort types.ActivitySort, total int64) {
+	key := sort.Key(true)
+
+	if query.Cursor != "" {
+		var position activityCursor
+		err := types.DecodeCursor(query.Cursor, &position)
+		if err == nil && (position.Sort != key || position.Order != sort.Order) {
+			err = errors.New("cursor was created for another sort order")
+		}
+		var value interface{}
+		if err == nil {
+			value, err = decodeActivitySortValue(position.Value, key)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+				"INVALID_QUERY",
+				"Invalid pagination parameters",
+				err.Error(),
+			))
+			return
+		}
+
+		comparison := "<"
+		if sort.Order == "asc" {
+			comparison = ">"
+		}
+		db = db.Where("("+activitySortColumns[key]+", activities.id) "+comparison+" (?, ?)", value, position.ID)
+	}
+
+	// Fetch one extra row to know whether there is a next page
+	var activities []models.Activity
+	if err := applyActivitySort(db, key, sort.Order).Limit(pageSize + 1).Find(&activities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch activities",
+			err.Error(),
+		))
+		return
+	}
+
+	response := types.CursorResponse{PageSize: pageSize}
+	if query.WantsTotal() {
+		response.TotalItems = &total
+	}
+
+	if len(activities) > pageSize {
+		activities = activities[:pageSize]
+		last := activities[len(activities)-1]
+
+		next, err := encodeActivityCursor(last, key, sort.Order)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+				"CURSOR_ERROR",
+				"Failed to create cursor",
+				err.Error(),
+			))
+			return
+		}
+		response.HasMore = true
+		response.NextCursor = next
+	}
+
+	c.JSON(http.StatusOK, types.NewCursorSuccessResponse(
+		"Activities retrieved successfully",
+		activities,
+		&response,
+	))
+}
+
+// encodeActivityCursor creates the cursor pointing after an activity
+func encodeActivityCursor(activity models.Activity, key, order string) (string, error) {
+	value, err := json.Marshal(activitySortValue(activity, key))
+	if err != nil {
+		return "", err
+	}
+	return types.EncodeCursor(activityCursor{Sort: key, Order: order, Value: value, ID: activity.ID})
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"bufio"
+	"dailyact/models"
+	"dailyact/types"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is the number of activities loaded and decrypted at a time during an export
+const exportBatchSize = 500
+
+// exportContentTypes maps the export formats to their content types
+var exportContentTypes = map[string]string{
+	"csv":    "text/csv; charset=utf-8",
+	"json":   "application/json; charset=utf-8",
+	"ndjson": "application/x-ndjson; charset=utf-8",
+}
+
+// exportedActivity converts an activity loaded with its category and tags for export
+func exportedActivity(activity models.Activity, loc *time.Location) types.ExportedActivity {
+	exported := types.ExportedActivity{
+		ID:              activity.ID,
+		Date:            activity.Date.Format("2006-01-02"),
+		StartTime:       activity.StartTime.In(loc).Format(time.RFC3339),
+		DurationSeconds: activity.Duration,
+		Category:        activity.Category.Name,
+		Description:     activity.Description.String(),
+		Notes:           activity.Notes.String(),
+		Tags:            make([]string, len(activity.Tags)),
+		Mood:            activity.Mood,
+		Energy:          activity.Energy,
+	}
+	if activity.EndTime != nil {
+		end := activity.EndTime.In(loc).Format(time.RFC3339)
+		exported.EndTime = &end
+	}
+	for i, tag := range activity.Tags {
+		exported.Tags[i] = tag.Name
+	}
+	return exported
+}
+
+// exportWriter writes exported activities in one format
+type exportWriter interface {
+	Write(activity types.ExportedActivity) error
+	Close() error
+}
+
+// csvCell keeps spreadsheet apps from reading user text as a formula
+func csvCell(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+// optionalInt formats an optional number, empty when missing
+func optionalInt(value *int) string {
+	if value == nil {
+		return ""
+	}
+	return strconv.Itoa(*value)
+}
+
+type csvExportWriter struct {
+	out *csv.Writer
+}
+
+func newCSVExportWriter(w io.Writer) (*csvExportWriter, error) {
+	out := csv.NewWriter(w)
+	err := out.Write([]string{
+		"id", "date", "start_time", "end_time", "duration_seconds",
+		"category", "description", "notes", "tags", "mood", "energy",
+	})
+	return &csvExportWriter{out: out}, err
+}
+
+func (w *csvExportWriter) Write(activity types.ExportedActivity) error {
+	endTime := ""
+	if activity.EndTime != nil {
+		endTime = *activity.EndTime
+	}
+	tags := make([]string, len(activity.Tags))
+	for i, tag := range activity.Tags {
+		tags[i] = csvCell(tag)
+	}
+	return w.out.Write([]string{
+		strconv.FormatUint(uint64(activity.ID), 10),
+		activity.Date,
+		activity.StartTime,
+		endTime,
+		strconv.Itoa(activity.DurationSeconds),
+		csvCell(activity.Category),
+		csvCell(activity.Description),
+		csvCell(activity.Notes),
+		strings.Join(tags, ";"),
+		optionalInt(activity.Mood),
+		optionalInt(activity.Energy),
+	})
+}
+
+func (w *csvExportWriter) Close() error {
+	w.out.Flush()
+	return w.out.Error()
+}
+
+// jsonExportWriter writes a JSON array one element at a time, or one object per line for NDJSON
+type jsonExportWriter struct {
+	out     *bufio.Writer
+	ndjson  bool
+	written int
+}
+
+func newJSONExportWriter(w io.Writer, ndjson bool) (*jsonExportWriter, error) {
+	out := bufio.NewWriter(w)
+	if !ndjson {
+		if _, err := out.WriteString("["); err != nil {
+			return nil, err
+		}
+	}
+	return &jsonExportWriter{out: out, ndjson: ndjson}, nil
+}
+
+func (w *jsonExportWriter) Write(activity types.ExportedActivity) error {
+	raw, err := json.Marshal(activity)
+	if err != nil {
+		return err
+	}
+	if !w.ndjson && w.written > 0 {
+		w.out.WriteString(",")
+	}
+	w.out.Write(raw)
+	if w.ndjson {
+		w.out.WriteString("\n")
+	}
+	w.written++
+	return nil
+}
+
+func (w *jsonExportWriter) Close() error {
+	if !w.ndjson {
+		w.out.WriteString("]")
+	}
+	return w.out.Flush()
+}
+
+// ExportActivities streams the current user's activities as CSV, JSON or NDJSON.
+// Activities are loaded and decrypted in batches, so the export never sits in memory whole.
+func (h *Handler) ExportActivities(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	loc := user.Location()
+
+	var query struct {
+		Format string `form:"format,default=csv" binding:"oneof=csv json ndjson"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid export format",
+			err.Error(),
+		))
+		return
+	}
+
+	var filter types.ActivityFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid filter parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	db := h.db.Model(&models.Activity{}).Where("activities.user_id = ?", user.ID)
+	db, err := h.applyActivityFilter(db, filter, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid filter parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	filename := "activities-" + time.Now().In(loc).Format("2006-01-02") + "." + query.Format
+	c.Header("Content-Type", exportContentTypes[query.Format])
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	var writer exportWriter
+	if query.Format == "csv" {
+		writer, err = newCSVExportWriter(c.Writer)
+	} else {
+		writer, err = newJSONExportWriter(c.Writer, query.Format == "ndjson")
+	}
+	if err == nil {
+		err = h.writeExport(db, writer, loc)
+	}
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// The status is already sent, the client sees a truncated file
+		log.Println("Failed to export activities:", err)
+	}
+}
+
+// writeExport loads the activities of the query in batches ordered by (start_time, id)
+// and writes them out one by one
+func (h *Handler) writeExport(db *gorm.DB, writer exportWriter, loc *time.Location) error {
+	var lastStart time.Time
+	var lastID uint
+	for {
+		batch := db.Session(&gorm.Session{})
+		if lastID != 0 {
+			batch = batch.Where("(activities.start_time, activities.id) > (?, ?)", lastStart, lastID)
+		}
+
+		var activities []models.Activity
+		if err := batch.Preload("Category").Preload("Tags").
+			Order("activities.start_time, activities.id").
+			Limit(exportBatchSize).
+			Find(&activities).Error; err != nil {
+			return err
+		}
+
+		for _, activity := range activities {
+			if err := writer.Write(exportedActivity(activity, loc)); err != nil {
+				return err
+			}
+		}
+		if len(activities) < exportBatchSize {
+			return nil
+		}
+		last := activities[len(activities)-1]
+		lastStart, lastID = last.StartTime, last.ID
+	}
+}
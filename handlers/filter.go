@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"dailyact/types"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// liveDurationSQL is the duration of an activity in seconds, counting running activities up to now
+const liveDurationSQL = "(CASE WHEN activities.end_time IS NULL " +
+	"THEN EXTRACT(EPOCH FROM NOW() - activities.start_time)::bigint ELSE activities.duration END)"
+
+// applyActivityFilter narrows an activities query to the given filter.
+// Dates and times of day are read in loc, the time zone of the user owning the activities.
+func (h *Handler) applyActivityFilter(db *gorm.DB, filter types.ActivityFilter, loc *time.Location) (*gorm.DB, error) {
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+
+	categoryIDs, err := filter.CategoryIDList()
+	if err != nil {
+		return nil, err
+	}
+	if len(categoryIDs) > 0 {
+		db = db.Where("activities.category_id IN ?", categoryIDs)
+	}
+
+	if filter.MinDuration != nil {
+		db = db.Where(liveDurationSQL+" >= ?", *filter.MinDuration)
+	}
+	if filter.MaxDuration != nil {
+		db = db.Where(liveDurationSQL+" <= ?", *filter.MaxDuration)
+	}
+
+	if filter.StartedAfter != nil || filter.StartedBefore != nil {
+		db = applyTimeOfDayFilter(db, filter, loc.String())
+	}
+
+	if names := filter.TagNames(); len(names) > 0 {
+		tagged := "SELECT activity_tags.activity_id FROM activity_tags " +
+			"JOIN tags ON tags.id = activity_tags.tag_id WHERE tags.name IN ?"
+		if filter.TagMatch == "all" {
+			db = db.Where("activities.id IN ("+tagged+" GROUP BY activity_tags.activity_id HAVING COUNT(DISTINCT tags.name) = ?)", names, len(names))
+		} else {
+			db = db.Where("activities.id IN ("+tagged+")", names)
+		}
+	}
+
+	if filter.Pending != nil {
+		db = db.Where("activities.pending = ?", *filter.Pending)
+	} else if !filter.IncludePending {
+		db = db.Where("activities.pending = false")
+	}
+
+	if filter.Query != nil {
+		db = h.searchActivities(db, *filter.Query)
+	}
+
+	start, end, err := filterDateRange(filter, loc)
+	if err != nil {
+		return nil, err
+	}
+	if start != nil {
+		db = db.Where("activities.start_time >= ?", *start)
+	}
+	if end != nil {
+		db = db.Where("activities.start_time < ?", *end)
+	}
+	return db, nil
+}
+
+// applyTimeOfDayFilter keeps activities starting within the started_after and started_before
+// times of day in the time zone tz. A window wrapping midnight, such as 22:00 to 02:00, is
+// accepted and matches either side of it.
+func applyTimeOfDayFilter(db *gorm.DB, filter types.ActivityFilter, tz string) *gorm.DB {
+	localTime := "(activities.start_time AT TIME ZONE ?)::time"
+
+	var after, before string
+	if filter.StartedAfter != nil {
+		after, _ = types.ParseTimeOfDay(*filter.StartedAfter)
+	}
+	if filter.StartedBefore != nil {
+		before, _ = types.ParseTimeOfDay(*filter.StartedBefore)
+	}
+
+	switch {
+	case before == "":
+		return db.Where(localTime+" >= ?::time", tz, after)
+	case after == "":
+		return db.Where(localTime+" < ?::time", tz, before)
+	case after <= before:
+		return db.Where(localTime+" >= ?::time AND "+localTime+" < ?::time", tz, after, tz, before)
+	default:
+		return db.Where("("+localTime+" >= ?::time OR "+localTime+" < ?::time)", tz, after, tz, before)
+	}
+}
+
+// filterDateRange returns the instants bounding the filter's days in loc, the end being exclusive
+func filterDateRange(filter types.ActivityFilter, loc *time.Location) (start, end *time.Time, err error) {
+	if filter.StartDate != nil {
+		day, err := types.ParseDay(*filter.StartDate, loc)
+		if err != nil {
+			return nil, nil, err
+		}
+		start = &day
+	}
+	if filter.EndDate != nil {
+		day, err := types.ParseDay(*filter.EndDate, loc)
+		if err != nil {
+			return nil, nil, err
+		}
+		next := day.AddDate(0, 0, 1)
+		end = &next
+	}
+	return start, end, nil
+}
+
+// daySlicesQuery splits activities at midnight in the time zone tz. It returns one
+// row per activity and local day the activity touches, with the seconds falling on
+// that day (activity_id, category_id, local_day, seconds). Running activities count
+// up to now. activities must be a query on the activities table.
+func daySlicesQuery(db *gorm.DB, activities *gorm.DB, tz string) *gorm.DB {
+	spans := activities.Select("activities.id, activities.category_id, activities.start_time, " +
+		"COALESCE(activities.end_time, NOW()) AS end_time")
+
+	return db.Table("(?) AS a", spans).
+		Select("a.id AS activity_id, a.category_id, d.local_day, "+
+			"EXTRACT(EPOCH FROM LEAST(a.end_time, (d.local_day + interval '1 day') AT TIME ZONE ?) "+
+			"- GREATEST(a.start_time, d.local_day AT TIME ZONE ?)) AS seconds",
+			tz, tz).
+		Joins("CROSS JOIN LATERAL generate_series("+
+			"date_trunc('day', a.start_time AT TIME ZONE ?), "+
+			"date_trunc('day', a.end_time AT TIME ZONE ?), "+
+			"interval '1 day') AS d(local_day)",
+			tz, tz)
+}
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/types"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// findGaps returns the intervals within [start, end) not covered by any of the user's
+// confirmed activities, running activities covering up to now
+func findGaps(db *gorm.DB, userID uint, start, end time.Time) ([]types.Gap, error) {
+	gaps := []types.Gap{}
+	if !end.After(start) {
+		return gaps, nil
+	}
+
+	var activities []models.Activity
+	if err := db.Select("id", "start_time", "end_time").
+		Where("user_id = ? AND pending = false AND start_time < ? AND COALESCE(end_time, NOW()) > ?", userID, end, start).
+		Order("start_time").
+		Find(&activities).Error; err != nil {
+		return nil, err
+	}
+
+	// Walk the activities in start order, everything between the covered parts is a gap
+	now := time.Now()
+	covered := start
+	for _, activity := range activities {
+		activityEnd := now
+		if activity.EndTime != nil {
+			activityEnd = *activity.EndTime
+		}
+		if activity.StartTime.After(covered) {
+			gaps = append(gaps, types.Gap{
+				StartTime: covered,
+				EndTime:   activity.StartTime,
+				Seconds:   int64(activity.StartTime.Sub(covered).Seconds()),
+			})
+		}
+		if activityEnd.After(covered) {
+			covered = activityEnd
+		}
+	}
+	if end.After(covered) {
+		gaps = append(gaps, types.Gap{
+			StartTime: covered,
+			EndTime:   end,
+			Seconds:   int64(end.Sub(covered).Seconds()),
+		})
+	}
+	return gaps, nil
+}
+
+// GetGaps returns the intervals of a day in the user's time zone without any activity logged
+func (h *Handler) GetGaps(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	loc := user.Location()
+
+	var query struct {
+		Date       *string `form:"date"` // YYYY-MM-DD, defaults to today
+		MinMinutes int     `form:"min_minutes,default=1" binding:"min=0"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid query parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	now := time.Now()
+	local := now.In(loc)
+	dayStart := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	if query.Date != nil {
+		day, err := types.ParseDay(*query.Date, loc)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+				"INVALID_QUERY",
+				"Invalid query parameters",
+				"date must be formatted as YYYY-MM-DD",
+			))
+			return
+		}
+		dayStart = day
+	}
+
+	// The part of the day that has not happened yet is not a gap
+	dayEnd := dayStart.AddDate(0, 0, 1)
+	elapsedEnd := dayEnd
+	if elapsedEnd.After(now) {
+		elapsedEnd = now
+	}
+
+	gaps, err := findGaps(h.db, user.ID, dayStart, elapsedEnd)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to find gaps",
+			err.Error(),
+		))
+		return
+	}
+
+	result := types.DayGaps{
+		Date: dayStart.Format("2006-01-02"),
+		Gaps: []types.Gap{},
+	}
+	for _, gap := range gaps {
+		result.UntrackedSeconds += gap.Seconds
+		if gap.Seconds >= int64(query.MinMinutes)*60 {
+			result.Gaps = append(result.Gaps, gap)
+		}
+	}
+	if elapsedEnd.After(dayStart) {
+		result.TrackedSeconds = int64(elapsedEnd.Sub(dayStart).Seconds()) - result.UntrackedSeconds
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Gaps retrieved successfully",
+		result,
+		nil,
+	))
+}
+
+// FillGaps creates activities for untracked intervals in one transaction. An interval
+// overlapping logged time is refused like an overlapping activity, so either all
+// activities are created or none.
+func (h *Handler) FillGaps(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var input types.FillGapsRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+
+	now := time.Now()
+	tagNames := make([][]string, len(input.Gaps))
+	categoryIDs := map[uint]bool{}
+	for i, gap := range input.Gaps {
+		if !gap.EndTime.After(gap.StartTime) || gap.EndTime.After(now) {
+			c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+				"INVALID_INPUT",
+				"Invalid input data",
+				fmt.Sprintf("gap %d must end after it starts and not end in the future", i),
+			))
+			return
+		}
+
+		names, err := normalizeTagNames(gap.Tags)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+				"INVALID_INPUT",
+				"Invalid input data",
+				err.Error(),
+			))
+			return
+		}
+		tagNames[i] = names
+		categoryIDs[gap.CategoryID] = true
+	}
+
+	exist, err := categoriesExist(h.db, categoryIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to check categories",
+			err.Error(),
+		))
+		return
+	}
+	if !exist {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Category not found",
+			"Every gap needs an existing category",
+		))
+		return
+	}
+
+	activities := make([]models.Activity, len(input.Gaps))
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockUser(tx, user.ID); err != nil {
+			return err
+		}
+		for i, gap := range input.Gaps {
+			endTime := gap.EndTime
+			activities[i] = models.Activity{
+				StartTime:  gap.StartTime,
+				EndTime:    &endTime,
+				CategoryID: gap.CategoryID,
+				UserID:     user.ID,
+			}
+			if err := h.insertActivity(tx, &activities[i], gap.Description, gap.Notes, tagNames[i], types.OverlapReject); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		respondSaveError(c, err, "Failed to fill gaps")
+		return
+	}
+
+	// Reload the activities so the response carries decrypted fields
+	created := make([]uint, len(activities))
+	for i, activity := range activities {
+		created[i] = activity.ID
+	}
+	var result []models.Activity
+	if err := h.db.Preload("Category").Preload("Tags").Where("id IN ?", created).Order("start_time").Find(&result).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to reload activity data",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusCreated, types.NewSuccessResponse(
+		"Gaps filled successfully",
+		result,
+		nil,
+	))
+}
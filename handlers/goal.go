@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/types"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Goal handlers
+func (h *Handler) CreateGoal(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var input models.GoalRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.First(&models.Category{}, input.CategoryID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Category not found",
+			err.Error(),
+		))
+		return
+	}
+
+	goal := models.Goal{
+		UserID:        user.ID,
+		CategoryID:    input.CategoryID,
+		Kind:          input.Kind,
+		Period:        input.Period,
+		TargetSeconds: input.TargetSeconds,
+	}
+
+	if err := h.db.Create(&goal).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to create goal",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.Preload("Category").First(&goal, goal.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to reload goal data",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusCreated, types.NewSuccessResponse(
+		"Goal created successfully",
+		goal,
+		nil,
+	))
+}
+
+func (h *Handler) GetGoals(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var query types.PaginationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid pagination parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	db := h.db.Model(&models.Goal{}).Where("user_id = ?", user.ID)
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to count goals",
+			err.Error(),
+		))
+		return
+	}
+
+	var goals []models.Goal
+	offset := (query.Page - 1) * query.PageSize
+	if err := db.Preload("Category").Order("created_at DESC").Offset(offset).Limit(query.PageSize).Find(&goals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch goals",
+			err.Error(),
+		))
+		return
+	}
+
+	pagination := types.NewPaginationResponse(query.Page, query.PageSize, total)
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Goals retrieved successfully",
+		goals,
+		&pagination,
+	))
+}
+
+func (h *Handler) GetGoalByID(c *gin.Context) {
+	var goal models.Goal
+	if err := h.db.Preload("Category").First(&goal, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Goal not found",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Goal retrieved successfully",
+		goal,
+		nil,
+	))
+}
+
+func (h *Handler) UpdateGoal(c *gin.Context) {
+	var goal models.Goal
+	if err := h.db.First(&goal, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Goal not found",
+			err.Error(),
+		))
+		return
+	}
+
+	var input models.GoalRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.First(&models.Category{}, input.CategoryID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Category not found",
+			err.Error(),
+		))
+		return
+	}
+
+	goal.CategoryID = input.CategoryID
+	goal.Kind = input.Kind
+	goal.Period = input.Period
+	goal.TargetSeconds = input.TargetSeconds
+
+	if err := h.db.Save(&goal).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to update goal",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.Preload("Category").First(&goal, goal.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to reload goal data",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Goal updated successfully",
+		goal,
+		nil,
+	))
+}
+
+func (h *Handler) DeleteGoal(c *gin.Context) {
+	var goal models.Goal
+	if err := h.db.First(&goal, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Goal not found",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.Delete(&goal).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to delete goal",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Goal deleted successfully",
+		nil,
+		nil,
+	))
+}
+
+// GetGoalProgress returns the attainment of each of the user's goals in its current period
+func (h *Handler) GetGoalProgress(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	loc := user.Location()
+	now := time.Now()
+
+	var goals []models.Goal
+	if err := h.db.Preload("Category").Where("user_id = ?", user.ID).Order("created_at").Find(&goals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch goals",
+			err.Error(),
+		))
+		return
+	}
+
+	progress := make([]models.GoalProgress, 0, len(goals))
+	for _, goal := range goals {
+		start, end := goal.Period.Bounds(now, loc)
+
+		seconds, err := categorySeconds(h.db, user.ID, goal.CategoryID, start, end)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+				"DB_ERROR",
+				"Failed to compute goal progress",
+				err.Error(),
+			))
+			return
+		}
+
+		progress = append(progress, models.GoalProgress{
+			Goal:          goal,
+			PeriodStart:   start,
+			PeriodEnd:     end,
+			ActualSeconds: seconds,
+			Percent:       math.Round(float64(seconds)/float64(goal.TargetSeconds)*10000) / 100,
+			Met:           goal.IsMet(seconds),
+		})
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Goal progress retrieved successfully",
+		progress,
+		nil,
+	))
+}
+
+// categorySeconds returns the seconds the user spent on a category within [start, end).
+// Activities reaching outside the range only count for the part inside it, pending
+// activities do not count.
+func categorySeconds(db *gorm.DB, userID, categoryID uint, start, end time.Time) (int64, error) {
+	var seconds int64
+	err := db.Model(&models.Activity{}).
+		Select("COALESCE(SUM(EXTRACT(EPOCH FROM LEAST(COALESCE(end_time, NOW()), ?) - GREATEST(start_time, ?))), 0)::bigint", end, start).
+		Where("user_id = ? AND category_id = ? AND pending = false", userID, categoryID).
+		Where("start_time < ? AND COALESCE(end_time, NOW()) > ?", end, start).
+		Scan(&seconds).Error
+	return seconds, err
+}
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"dailyact/models"
+	"dailyact/storage"
 	"dailyact/types"
 	"net/http"
 	"time"
@@ -11,12 +12,25 @@ import (
 )
 
 type Handler struct {
-	db                *gorm.DB
-	encryptionService *models.EncryptionService
+	db                    *gorm.DB
+	encryptionService     *models.EncryptionService
+	blindIndexService     *models.BlindIndexService
+	fileEncryptionService *models.FileEncryptionService
+	attachmentStorage     storage.Storage
 }
 
-func NewHandler(db *gorm.DB, encryptionService *models.EncryptionService) (*Handler, error) {
-	return &Handler{db: db, encryptionService: encryptionService}, nil
+func NewHandler(db *gorm.DB, encryptionService *models.EncryptionService, blindIndexService *models.BlindIndexService, attachmentStorage storage.Storage) (*Handler, error) {
+	fileEncryptionService, err := models.NewFileEncryptionService(encryptionService)
+	if err != nil {
+		return nil, err
+	}
+	return &Handler{
+		db:                    db,
+		encryptionService:     encryptionService,
+		blindIndexService:     blindIndexService,
+		fileEncryptionService: fileEncryptionService,
+		attachmentStorage:     attachmentStorage,
+	}, nil
 }
 func (h *Handler) CreateCategory(c *gin.Context) {
 	var category models.Category
@@ -140,7 +154,7 @@ func (h *Handler) DeleteCategory(c *gin.Context) {
 		return
 	}
 
-	// Check if category is being used by any activities
+	// Check if category is being used by any activities, the trash does not count
 	var activityCount int64
 	if err := h.db.Model(&models.Activity{}).Where("category_id = ?", id).Count(&activityCount).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
@@ -160,8 +174,17 @@ func (h *Handler) DeleteCategory(c *gin.Context) {
 		return
 	}
 
-	// Delete category
-	if err := h.db.Delete(&category).Error; err != nil {
+	// Delete category, activities of it still in the trash are purged with it
+	var attachmentKeys []string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		attachmentKeys, err = purgeActivities(tx, tx.Unscoped().Model(&models.Activity{}).Select("id").Where("category_id = ?", category.ID))
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&category).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
 			"DB_ERROR",
 			"Failed to delete category",
@@ -169,6 +192,7 @@ func (h *Handler) DeleteCategory(c *gin.Context) {
 		))
 		return
 	}
+	h.deleteStoredAttachments(attachmentKeys)
 
 	c.JSON(http.StatusOK, types.NewSuccessResponse(
 		"Category deleted successfully",
@@ -188,7 +212,10 @@ func (h *Handler) CreateActivity(c *gin.Context) {
 		EndTime     time.Time `json:"end_time" binding:"required"`
 		Description string    `json:"description" binding:"required"`
 		Notes       string    `json:"notes"`
+		Mood        *int      `json:"mood" binding:"omitempty,min=1,max=5"`
+		Energy      *int      `json:"energy" binding:"omitempty,min=1,max=5"`
 		CategoryID  uint      `json:"category_id" binding:"required"`
+		Tags        []string  `json:"tags"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -200,47 +227,45 @@ func (h *Handler) CreateActivity(c *gin.Context) {
 		return
 	}
 
-	// Encrypt description and notes
-	descriptionEncrypted, err := h.encryptionService.Encrypt(input.Description)
+	tagNames, err := normalizeTagNames(input.Tags)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
-			"ENCRYPTION_ERROR",
-			"Failed to encrypt description",
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
 			err.Error(),
 		))
 		return
 	}
 
-	var notesEncrypted string
-	if input.Notes != "" {
-		notesEncrypted, err = h.encryptionService.Encrypt(input.Notes)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
-				"ENCRYPTION_ERROR",
-				"Failed to encrypt notes",
-				err.Error(),
-			))
-			return
-		}
+	var overlapQuery types.OverlapQuery
+	if err := c.ShouldBindQuery(&overlapQuery); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid overlap policy",
+			err.Error(),
+		))
+		return
 	}
 
-	// Create activity with encrypted data
 	activity := models.Activity{
-		Date:        input.Date,
-		StartTime:   input.StartTime,
-		EndTime:     input.EndTime,
-		Description: models.EncryptedString(descriptionEncrypted),
-		Notes:       models.EncryptedString(notesEncrypted),
-		CategoryID:  input.CategoryID,
-		UserID:      user.(models.User).ID,
-	}
-
-	if err := h.db.Create(&activity).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
-			"DB_ERROR",
-			"Failed to create activity",
-			err.Error(),
-		))
+		Date:       input.Date,
+		StartTime:  input.StartTime,
+		EndTime:    &input.EndTime,
+		Mood:       input.Mood,
+		Energy:     input.Energy,
+		CategoryID: input.CategoryID,
+		UserID:     user.(models.User).ID,
+	}
+
+	// Check overlaps and create inside one transaction so concurrent requests cannot both pass
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockUser(tx, activity.UserID); err != nil {
+			return err
+		}
+		return h.insertActivity(tx, &activity, input.Description, input.Notes, tagNames, overlapQuery.Overlap)
+	})
+	if err != nil {
+		respondSaveError(c, err, "Failed to create activity")
 		return
 	}
 
@@ -254,7 +279,9 @@ func (h *Handler) CreateActivity(c *gin.Context) {
 func (h *Handler) GetActivities(c *gin.Context) {
 	user, _ := c.Get("user")
 	var query types.PaginationQuery
+	var cursorQuery types.CursorQuery
 	var filter types.ActivityFilter
+	var sort types.ActivitySort
 
 	// Bind pagination query
 	if err := c.ShouldBindQuery(&query); err != nil {
@@ -265,43 +292,71 @@ func (h *Handler) GetActivities(c *gin.Context) {
 		))
 		return
 	}
+	if err := c.ShouldBindQuery(&cursorQuery); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid pagination parameters",
+			err.Error(),
+		))
+		return
+	}
+	if err := c.ShouldBindQuery(&sort); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid sort parameters",
+			err.Error(),
+		))
+		return
+	}
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid filter parameters",
+			err.Error(),
+		))
+		return
+	}
 
 	// Start building base query
-	db := h.db.Model(&models.Activity{}).Preload("Category").Preload("User")
-	db = db.Where("user_id = ?", user.(models.User).ID)
+	db := h.db.Model(&models.Activity{}).Preload("Category").Preload("User").Preload("Tags")
+	db = db.Where("activities.user_id = ?", user.(models.User).ID)
 
-	// Bind and apply filters
-	if err := c.ShouldBindQuery(&filter); err == nil {
-		if filter.CategoryID != nil {
-			db = db.Where("category_id = ?", *filter.CategoryID)
-		}
-		if filter.StartDate != nil {
-			if start, err := time.Parse("2006-01-02", *filter.StartDate); err == nil {
-				db = db.Where("date >= ?", start)
-			}
-		}
-		if filter.EndDate != nil {
-			if end, err := time.Parse("2006-01-02", *filter.EndDate); err == nil {
-				db = db.Where("date <= ?", end)
-			}
-		}
+	// Apply filters, the list is where pending activities are confirmed so it shows them
+	filter.IncludePending = true
+	db, err := h.applyActivityFilter(db, filter, user.(models.User).Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid filter parameters",
+			err.Error(),
+		))
+		return
 	}
 
-	// Count total items with applied filters
+	// Count total items with applied filters, unless the client opted out
 	var total int64
-	if err := db.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
-			"DB_ERROR",
-			"Failed to count activities",
-			err.Error(),
-		))
+	if cursorQuery.WantsTotal() {
+		if err := db.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+				"DB_ERROR",
+				"Failed to count activities",
+				err.Error(),
+			))
+			return
+		}
+	}
+
+	if cursorQuery.UseCursor() {
+		h.getActivitiesByCursor(c, db, query.PageSize, cursorQuery, sort, total)
 		return
 	}
 
-	// Fetch paginated activities, sorted by created_at desc (newest first)
+	// Fetch paginated activities, sorted by created_at desc (newest first) unless asked otherwise.
+	// One extra row tells whether there is a next page when the total is not counted.
 	var activities []models.Activity
 	offset := (query.Page - 1) * query.PageSize
-	if err := db.Order("created_at DESC").Offset(offset).Limit(query.PageSize).Find(&activities).Error; err != nil {
+	db = applyActivitySort(db, sort.Key(false), sort.Order)
+	if err := db.Offset(offset).Limit(query.PageSize + 1).Find(&activities).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
 			"DB_ERROR",
 			"Failed to fetch activities",
@@ -310,8 +365,16 @@ func (h *Handler) GetActivities(c *gin.Context) {
 		return
 	}
 
+	hasMore := len(activities) > query.PageSize
+	if hasMore {
+		activities = activities[:query.PageSize]
+	}
+
 	// Prepare pagination response
 	pagination := types.NewPaginationResponse(query.Page, query.PageSize, total)
+	if !cursorQuery.WantsTotal() {
+		pagination.HasMore = hasMore
+	}
 	c.JSON(http.StatusOK, types.NewSuccessResponse(
 		"Activities retrieved successfully",
 		activities,
@@ -321,7 +384,7 @@ func (h *Handler) GetActivities(c *gin.Context) {
 
 func (h *Handler) GetActivityByID(c *gin.Context) {
 	var activity models.Activity
-	if err := h.db.Preload("Category").First(&activity, c.Param("id")).Error; err != nil {
+	if err := h.db.Preload("Category").Preload("Tags").First(&activity, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, types.NewErrorResponse(
 			"NOT_FOUND",
 			"Activity not found",
@@ -337,6 +400,50 @@ func (h *Handler) GetActivityByID(c *gin.Context) {
 	))
 }
 
+// updateActivity stores the fields set on a loaded activity, encrypting the plaintext
+// description and notes and applying the overlap policy. The tags are replaced unless
+// tagNames is nil, and the previous values are kept as a revision by the editor. The
+// caller must hold the user lock (see lockUser).
+func (h *Handler) updateActivity(tx *gorm.DB, activity *models.Activity, description, notes string, tagNames *[]string, policy string, editorID uint) error {
+	descriptionEncrypted, err := h.encryptionService.Encrypt(description)
+	if err != nil {
+		return err
+	}
+	notesEncrypted, err := h.encryptionService.Encrypt(notes)
+	if err != nil {
+		return err
+	}
+	activity.Description = models.EncryptedString(descriptionEncrypted)
+	activity.Notes = models.EncryptedString(notesEncrypted)
+
+	before, err := loadActivityState(tx, activity.ID)
+	if err != nil {
+		return err
+	}
+	if err := h.resolveOverlaps(tx, activity.UserID, activity.ID, activity.StartTime, activity.EndTime, policy); err != nil {
+		return err
+	}
+	if err := tx.Save(activity).Error; err != nil {
+		return err
+	}
+	if err := h.indexActivity(tx, activity.ID, description, notes); err != nil {
+		return err
+	}
+
+	if tagNames != nil {
+		tags, err := findOrCreateTags(tx, activity.UserID, *tagNames)
+		if err != nil {
+			return err
+		}
+		if err := setActivityTags(tx, activity.ID, tags); err != nil {
+			return err
+		}
+	}
+
+	// Keep the previous values as a revision
+	return h.recordRevision(tx, before, editorID)
+}
+
 func (h *Handler) UpdateActivity(c *gin.Context) {
 	// Check if activity exists
 	var activity models.Activity
@@ -350,13 +457,17 @@ func (h *Handler) UpdateActivity(c *gin.Context) {
 	}
 
 	// Use a temporary struct for JSON binding
+	// end_time may be omitted to keep a running activity running
 	var input struct {
-		Date        time.Time `json:"date"`
-		StartTime   time.Time `json:"start_time" binding:"required"`
-		EndTime     time.Time `json:"end_time" binding:"required"`
-		Description string    `json:"description" binding:"required"`
-		Notes       string    `json:"notes"`
-		CategoryID  uint      `json:"category_id" binding:"required"`
+		Date        time.Time  `json:"date"`
+		StartTime   time.Time  `json:"start_time" binding:"required"`
+		EndTime     *time.Time `json:"end_time"`
+		Description string     `json:"description" binding:"required"`
+		Notes       string     `json:"notes"`
+		Mood        *int       `json:"mood" binding:"omitempty,min=1,max=5"`
+		Energy      *int       `json:"energy" binding:"omitempty,min=1,max=5"`
+		CategoryID  uint       `json:"category_id" binding:"required"`
+		Tags        *[]string  `json:"tags"` // omit to keep the current tags
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -368,61 +479,63 @@ func (h *Handler) UpdateActivity(c *gin.Context) {
 		return
 	}
 
-	// Create encryption service
-	encryptionService, err := models.NewEncryptionService()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
-			"ENCRYPTION_ERROR",
-			"Failed to initialize encryption service",
-			err.Error(),
-		))
-		return
-	}
-
-	// Encrypt description and notes
-	descriptionEncrypted, err := encryptionService.Encrypt(input.Description)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
-			"ENCRYPTION_ERROR",
-			"Failed to encrypt description",
-			err.Error(),
+	if input.EndTime == nil && !activity.IsRunning {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			"end_time is required for an activity that is not running",
 		))
 		return
 	}
 
-	var notesEncrypted string
-	if input.Notes != "" {
-		notesEncrypted, err = encryptionService.Encrypt(input.Notes)
+	var tagNames *[]string // nil keeps the current tags
+	if input.Tags != nil {
+		names, err := normalizeTagNames(*input.Tags)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
-				"ENCRYPTION_ERROR",
-				"Failed to encrypt notes",
+			c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+				"INVALID_INPUT",
+				"Invalid input data",
 				err.Error(),
 			))
 			return
 		}
+		tagNames = &names
+	}
+
+	var overlapQuery types.OverlapQuery
+	if err := c.ShouldBindQuery(&overlapQuery); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid overlap policy",
+			err.Error(),
+		))
+		return
 	}
 
 	// Update activity fields
 	activity.Date = input.Date
 	activity.StartTime = input.StartTime
 	activity.EndTime = input.EndTime
-	activity.Description = models.EncryptedString(descriptionEncrypted)
-	activity.Notes = models.EncryptedString(notesEncrypted)
+	activity.Mood = input.Mood
+	activity.Energy = input.Energy
 	activity.CategoryID = input.CategoryID
+	// Editing a pending activity from a recurring template confirms it
+	activity.Pending = false
 
-	// Save changes
-	if err := h.db.Save(&activity).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
-			"DB_ERROR",
-			"Failed to update activity",
-			err.Error(),
-		))
+	// Save changes, checking overlaps inside the same transaction
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockUser(tx, activity.UserID); err != nil {
+			return err
+		}
+		return h.updateActivity(tx, &activity, input.Description, input.Notes, tagNames, overlapQuery.Overlap, c.MustGet("user").(models.User).ID)
+	})
+	if err != nil {
+		respondSaveError(c, err, "Failed to update activity")
 		return
 	}
 
-	// Reload the activity with Category
-	if err := h.db.Preload("Category").First(&activity, activity.ID).Error; err != nil {
+	// Reload the activity with Category and Tags
+	if err := h.db.Preload("Category").Preload("Tags").First(&activity, activity.ID).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
 			"DB_ERROR",
 			"Failed to reload activity data",
@@ -438,6 +551,18 @@ func (h *Handler) UpdateActivity(c *gin.Context) {
 	))
 }
 
+// trashActivity moves an activity to the trash, a running timer is stopped first
+func trashActivity(tx *gorm.DB, activity *models.Activity) error {
+	if activity.EndTime == nil {
+		now := time.Now()
+		activity.EndTime = &now
+		if err := tx.Model(activity).Select("end_time", "duration", "updated_at").Updates(activity).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Delete(activity).Error
+}
+
 func (h *Handler) DeleteActivity(c *gin.Context) {
 	// Check if activity exists
 	var activity models.Activity
@@ -450,8 +575,10 @@ func (h *Handler) DeleteActivity(c *gin.Context) {
 		return
 	}
 
-	// Delete activity
-	if err := h.db.Delete(&activity).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		return trashActivity(tx, &activity)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
 			"DB_ERROR",
 			"Failed to delete activity",
@@ -461,7 +588,7 @@ func (h *Handler) DeleteActivity(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, types.NewSuccessResponse(
-		"Activity deleted successfully",
+		"Activity moved to trash",
 		nil,
 		nil,
 	))
@@ -0,0 +1,505 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/types"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	// maxImportSize is the largest import file accepted, in bytes
+	maxImportSize = 5 << 20
+	// maxImportRows is the largest number of activities accepted in one import
+	maxImportRows = 10000
+)
+
+// errImportRolledBack ends the import transaction of a dry run or of an import with invalid rows
+var errImportRolledBack = errors.New("import rolled back")
+
+// importRow is an activity read from an import file, not yet checked against the database
+type importRow struct {
+	Line        int
+	StartTime   time.Time
+	EndTime     time.Time
+	Description string
+	Notes       string
+	Category    string
+	CategoryID  uint // set instead of Category when the category is already resolved
+	Tags        []string
+	Mood        *int
+	Energy      *int
+	// ExternalSource and ExternalID identify rows imported from another time tracker
+	ExternalSource string
+	ExternalID     string
+}
+
+// importTimeFormats are tried in order when the mapping has no time format
+var importTimeFormats = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02T15:04:05", "2006-01-02T15:04"}
+
+// parseImportTime reads a time in loc unless the value carries its own offset
+func parseImportTime(value, layout string, loc *time.Location) (time.Time, error) {
+	if layout != "" {
+		return time.ParseInLocation(layout, value, loc)
+	}
+	for _, format := range importTimeFormats {
+		if parsed, err := time.ParseInLocation(format, value, loc); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as a time", value)
+}
+
+// parseImportDuration reads a duration given in seconds or as H:MM[:SS]
+func parseImportDuration(value string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("cannot parse %q as a duration", value)
+	}
+	var total time.Duration
+	units := []time.Duration{time.Hour, time.Minute, time.Second}
+	for i, part := range parts {
+		number, err := strconv.Atoi(part)
+		if err != nil || number < 0 {
+			return 0, fmt.Errorf("cannot parse %q as a duration", value)
+		}
+		total += time.Duration(number) * units[i]
+	}
+	return total, nil
+}
+
+// parseImportRating reads an optional 1 to 5 rating
+func parseImportRating(value string) (*int, error) {
+	if value == "" {
+		return nil, nil
+	}
+	rating, err := strconv.Atoi(value)
+	if err != nil || rating < 1 || rating > 5 {
+		return nil, fmt.Errorf("rating must be a number from 1 to 5, got %q", value)
+	}
+	return &rating, nil
+}
+
+// csvRecords reads the records of a CSV import that follow its header
+type csvRecords struct {
+	reader *csv.Reader
+	count  int
+}
+
+// next returns the next record that can be read and the line it starts on, recording the
+// records the CSV reader rejects as row errors. It returns io.EOF at the end of the file
+// and an error once the file holds more than maxImportRows records.
+func (r *csvRecords) next(rowErrors *[]types.ImportRowError) ([]string, int, error) {
+	for {
+		record, err := r.reader.Read()
+		if err == io.EOF {
+			return nil, 0, err
+		}
+		if r.count++; r.count > maxImportRows {
+			return nil, 0, fmt.Errorf("an import can hold at most %d rows", maxImportRows)
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, 0, err
+			}
+			*rowErrors = append(*rowErrors, types.ImportRowError{Row: parseErr.StartLine, Message: parseErr.Err.Error()})
+			continue
+		}
+		// Only a record that was read has field positions
+		line, _ := r.reader.FieldPos(0)
+		return record, line, nil
+	}
+}
+
+// parseImportCSV reads the rows of a CSV file with the given column mapping. Rows that
+// cannot be read are reported as row errors, a file that cannot be used at all is an error.
+func parseImportCSV(r io.Reader, mapping types.ImportMapping, loc *time.Location) ([]importRow, []types.ImportRowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read the CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	required := []string{mapping.StartTime, mapping.Category}
+	if mapping.Duration != "" {
+		required = append(required, mapping.Duration)
+	} else {
+		required = append(required, mapping.EndTime)
+	}
+	for _, name := range required {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("column %q not found in the CSV header", name)
+		}
+	}
+
+	var rows []importRow
+	var rowErrors []types.ImportRowError
+	records := csvRecords{reader: reader}
+	for {
+		record, line, err := records.next(&rowErrors)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// get returns a trimmed cell, undoing the formula guard of the CSV export
+		get := func(column string) string {
+			i, ok := columns[column]
+			if column == "" || !ok || i >= len(record) {
+				return ""
+			}
+			value := strings.TrimSpace(record[i])
+			if len(value) > 1 && value[0] == '\'' && strings.ContainsRune("=+-@", rune(value[1])) {
+				value = value[1:]
+			}
+			return value
+		}
+		fail := func(field, message string) {
+			rowErrors = append(rowErrors, types.ImportRowError{Row: line, Field: field, Message: message})
+		}
+
+		row := importRow{
+			Line:        line,
+			Description: get(mapping.Description),
+			Notes:       get(mapping.Notes),
+			Category:    get(mapping.Category),
+		}
+
+		start, err := parseImportTime(get(mapping.StartTime), mapping.TimeFormat, loc)
+		if err != nil {
+			fail("start_time", err.Error())
+			continue
+		}
+		row.StartTime = start
+
+		if mapping.Duration != "" {
+			duration, err := parseImportDuration(get(mapping.Duration))
+			if err != nil {
+				fail("duration", err.Error())
+				continue
+			}
+			row.EndTime = start.Add(duration)
+		} else {
+			end, err := parseImportTime(get(mapping.EndTime), mapping.TimeFormat, loc)
+			if err != nil {
+				fail("end_time", err.Error())
+				continue
+			}
+			row.EndTime = end
+		}
+		if row.EndTime.Before(row.StartTime) {
+			fail("end_time", models.ErrNegativeDuration.Error())
+			continue
+		}
+
+		if row.Category == "" {
+			fail("category", "category is required")
+			continue
+		}
+
+		if tags := get(mapping.Tags); tags != "" {
+			names, err := normalizeTagNames(strings.Split(tags, mapping.TagSeparator))
+			if err != nil {
+				fail("tags", err.Error())
+				continue
+			}
+			row.Tags = names
+		}
+
+		if row.Mood, err = parseImportRating(get(mapping.Mood)); err != nil {
+			fail("mood", err.Error())
+			continue
+		}
+		if row.Energy, err = parseImportRating(get(mapping.Energy)); err != nil {
+			fail("energy", err.Error())
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+	return rows, rowErrors, nil
+}
+
+// existingExternalIDs returns which of the external IDs the user already imported from the
+// source. Activities in the trash count as imported, as restoring them brings them back.
+func existingExternalIDs(tx *gorm.DB, userID uint, source string, externalIDs []string) (map[string]bool, error) {
+	existing := map[string]bool{}
+	if len(externalIDs) == 0 {
+		return existing, nil
+	}
+	var found []string
+	if err := tx.Unscoped().Model(&models.Activity{}).
+		Where("user_id = ? AND external_source = ? AND external_id IN ?", userID, source, externalIDs).
+		Pluck("external_id", &found).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range found {
+		existing[id] = true
+	}
+	return existing, nil
+}
+
+// categoriesExist reports whether every category of the set exists
+func categoriesExist(db *gorm.DB, categoryIDs map[uint]bool) (bool, error) {
+	if len(categoryIDs) == 0 {
+		return true, nil
+	}
+	ids := make([]uint, 0, len(categoryIDs))
+	for id := range categoryIDs {
+		ids = append(ids, id)
+	}
+	var found int64
+	if err := db.Model(&models.Category{}).Where("id IN ?", ids).Count(&found).Error; err != nil {
+		return false, err
+	}
+	return int(found) == len(ids), nil
+}
+
+// importRows creates the activities of the rows in one transaction holding the user lock.
+// Rows with an external ID already imported, or seen earlier in the same file, are skipped.
+// Rows with an unknown category or refused by the overlap policy are reported as row
+// errors, any other error aborts the import.
+func (h *Handler) importRows(tx *gorm.DB, userID uint, rows []importRow, policy string) ([]uint, int, []types.ImportRowError, error) {
+	var categories []models.Category
+	if err := tx.Select("id", "name").Find(&categories).Error; err != nil {
+		return nil, 0, nil, err
+	}
+	categoryIDs := map[string]uint{}
+	for _, category := range categories {
+		categoryIDs[strings.ToLower(category.Name)] = category.ID
+	}
+
+	externalIDs := map[string][]string{}
+	for _, row := range rows {
+		if row.ExternalID != "" {
+			externalIDs[row.ExternalSource] = append(externalIDs[row.ExternalSource], row.ExternalID)
+		}
+	}
+	imported := map[string]map[string]bool{}
+	for source, sourceIDs := range externalIDs {
+		existing, err := existingExternalIDs(tx, userID, source, sourceIDs)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		imported[source] = existing
+	}
+
+	var ids []uint
+	var skipped int
+	var rowErrors []types.ImportRowError
+	for _, row := range rows {
+		if row.ExternalID != "" {
+			if imported[row.ExternalSource][row.ExternalID] {
+				skipped++
+				continue
+			}
+			imported[row.ExternalSource][row.ExternalID] = true
+		}
+
+		categoryID := row.CategoryID
+		if categoryID == 0 {
+			var ok bool
+			if categoryID, ok = categoryIDs[strings.ToLower(row.Category)]; !ok {
+				rowErrors = append(rowErrors, types.ImportRowError{Row: row.Line, Field: "category", Message: fmt.Sprintf("category %q not found", row.Category)})
+				continue
+			}
+		}
+
+		endTime := row.EndTime
+		activity := models.Activity{
+			StartTime:  row.StartTime,
+			EndTime:    &endTime,
+			Mood:       row.Mood,
+			Energy:     row.Energy,
+			CategoryID: categoryID,
+			UserID:     userID,
+		}
+		if row.ExternalID != "" {
+			source, externalID := row.ExternalSource, row.ExternalID
+			activity.ExternalSource = &source
+			activity.ExternalID = &externalID
+		}
+		err := h.insertActivity(tx, &activity, row.Description, row.Notes, row.Tags, policy)
+		var overlap *overlapError
+		if errors.As(err, &overlap) {
+			rowErrors = append(rowErrors, types.ImportRowError{Row: row.Line, Message: fmt.Sprintf("%s: %v", overlap.Error(), overlap.ActivityIDs)})
+			continue
+		}
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		ids = append(ids, activity.ID)
+	}
+	return ids, skipped, rowErrors, nil
+}
+
+// runImport imports the parsed rows for the current user and writes the response. A dry
+// run, or a run with any invalid row, goes through every row and then rolls back.
+func (h *Handler) runImport(c *gin.Context, rows []importRow, rowErrors []types.ImportRowError, dryRun bool, policy string) {
+	user := c.MustGet("user").(models.User)
+
+	result := types.ImportResult{
+		DryRun:    dryRun,
+		TotalRows: len(rows) + len(rowErrors),
+		Errors:    rowErrors,
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockUser(tx, user.ID); err != nil {
+			return err
+		}
+		ids, skipped, insertErrors, err := h.importRows(tx, user.ID, rows, policy)
+		if err != nil {
+			return err
+		}
+		result.Errors = append(result.Errors, insertErrors...)
+		result.ValidRows = len(ids)
+		result.Skipped = skipped
+
+		if dryRun || len(result.Errors) > 0 {
+			return errImportRolledBack
+		}
+		result.Imported = len(ids)
+		result.ActivityIDs = ids
+		return nil
+	})
+	if err != nil && !errors.Is(err, errImportRolledBack) {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to import activities",
+			err.Error(),
+		))
+		return
+	}
+	if result.Errors == nil {
+		result.Errors = []types.ImportRowError{}
+	}
+
+	if !dryRun && len(result.Errors) > 0 {
+		c.JSON(http.StatusUnprocessableEntity, types.NewErrorResponseWithMeta(
+			"IMPORT_INVALID",
+			"Import has invalid rows, nothing was imported",
+			fmt.Sprintf("%d of %d rows are invalid", len(result.Errors), result.TotalRows),
+			gin.H{"result": result},
+		))
+		return
+	}
+
+	status, message := http.StatusCreated, "Activities imported successfully"
+	if dryRun {
+		status, message = http.StatusOK, "Import checked successfully"
+	}
+	c.JSON(status, types.NewSuccessResponse(message, result, nil))
+}
+
+// openImportFile opens the uploaded file in the multipart field "file", writing the error
+// response when there is none or it is too large
+func openImportFile(c *gin.Context) (multipart.File, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize+64<<10)
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return nil, false
+	}
+	if header.Size > maxImportSize {
+		c.JSON(http.StatusRequestEntityTooLarge, types.NewErrorResponse(
+			"IMPORT_TOO_LARGE",
+			"Import file is too large",
+			"Import files can be at most "+strconv.Itoa(maxImportSize>>20)+" MB",
+		))
+		return nil, false
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Failed to read import file",
+			err.Error(),
+		))
+		return nil, false
+	}
+	return file, true
+}
+
+// ImportActivities imports activities from the CSV file in the multipart field "file".
+// The optional "mapping" field holds a JSON column mapping (see types.ImportMapping).
+func (h *Handler) ImportActivities(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var query struct {
+		types.OverlapQuery
+		DryRun bool `form:"dry_run"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid query parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	file, ok := openImportFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var mapping types.ImportMapping
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &mapping); err != nil {
+			c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+				"INVALID_INPUT",
+				"Invalid column mapping",
+				err.Error(),
+			))
+			return
+		}
+	}
+	mapping = mapping.WithDefaults()
+
+	rows, rowErrors, err := parseImportCSV(file, mapping, user.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid import file",
+			err.Error(),
+		))
+		return
+	}
+
+	h.runImport(c, rows, rowErrors, query.DryRun, query.Overlap)
+}
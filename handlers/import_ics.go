@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"dailyact/ical"
+	"dailyact/models"
+	"dailyact/rrule"
+	"dailyact/types"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// icsSource is the external source of activities imported from calendar events
+const icsSource = "ics"
+
+// icsExternalID identifies an imported event by its UID, and an occurrence of a recurring
+// event by its UID and its original start. IDs too long for the column are hashed.
+func icsExternalID(uid string, occurrence time.Time) string {
+	id := uid
+	if !occurrence.IsZero() {
+		id += "/" + occurrence.UTC().Format("20060102T150405Z")
+	}
+	if len(id) > 255 {
+		sum := sha256.Sum256([]byte(id))
+		id = "sha256:" + hex.EncodeToString(sum[:])
+	}
+	return id
+}
+
+// icsCategory returns the category of the first rule whose keyword is in the summary
+func icsCategory(summary string, options types.ICSImportOptions) uint {
+	summary = strings.ToLower(summary)
+	for _, rule := range options.Rules {
+		if strings.Contains(summary, strings.ToLower(strings.TrimSpace(rule.Keyword))) {
+			return rule.CategoryID
+		}
+	}
+	return options.DefaultCategoryID
+}
+
+// icsImportRows turns the events starting in [from, to) into import rows, expanding
+// recurring events. Occurrences removed by EXDATE or replaced by another event are left
+// out, as are cancelled events, events without a duration and, unless asked for,
+// all-day events.
+func icsImportRows(events []ical.Event, options types.ICSImportOptions, from, to time.Time) ([]importRow, []types.ImportRowError, error) {
+	// Occurrences of recurring events that are replaced by an event of their own
+	replaced := map[string]bool{}
+	for _, event := range events {
+		if !event.RecurrenceID.IsZero() {
+			replaced[icsExternalID(event.UID, event.RecurrenceID)] = true
+		}
+	}
+
+	var rows []importRow
+	var rowErrors []types.ImportRowError
+	add := func(event ical.Event, start, end time.Time, externalID string) error {
+		if len(rows) >= maxImportRows {
+			return fmt.Errorf("an import can hold at most %d activities", maxImportRows)
+		}
+		categoryID := icsCategory(event.Summary, options)
+		if categoryID == 0 {
+			rowErrors = append(rowErrors, types.ImportRowError{Row: event.Line, Field: "category", Message: fmt.Sprintf("no category rule matches %q", event.Summary)})
+			return nil
+		}
+		rows = append(rows, importRow{
+			Line:           event.Line,
+			StartTime:      start,
+			EndTime:        end,
+			Description:    event.Summary,
+			Notes:          event.Description,
+			CategoryID:     categoryID,
+			ExternalSource: icsSource,
+			ExternalID:     externalID,
+		})
+		return nil
+	}
+
+	for _, event := range events {
+		if event.Status == "CANCELLED" || event.Duration == 0 || event.AllDay && !options.IncludeAllDay {
+			continue
+		}
+		if event.UID == "" {
+			rowErrors = append(rowErrors, types.ImportRowError{Row: event.Line, Field: "uid", Message: "event has no UID"})
+			continue
+		}
+
+		if event.RRule == "" || !event.RecurrenceID.IsZero() {
+			if event.Start.Before(from) || !event.Start.Before(to) {
+				continue
+			}
+			if err := add(event, event.Start, event.End, icsExternalID(event.UID, event.RecurrenceID)); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
+		rule, err := rrule.Parse(event.RRule, event.Start.Location())
+		if err != nil {
+			rowErrors = append(rowErrors, types.ImportRowError{Row: event.Line, Field: "rrule", Message: err.Error()})
+			continue
+		}
+		excluded := map[int64]bool{}
+		for _, exdate := range event.ExDates {
+			excluded[exdate.Unix()] = true
+		}
+		for _, occurrence := range rule.Between(event.Start, from, to) {
+			externalID := icsExternalID(event.UID, occurrence)
+			if excluded[occurrence.Unix()] || replaced[externalID] {
+				continue
+			}
+			end := occurrence.Add(event.Duration)
+			if event.AllDay {
+				end = occurrence.AddDate(0, 0, int(event.Duration/(24*time.Hour)))
+			}
+			if err := add(event, occurrence, end, externalID); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return rows, rowErrors, nil
+}
+
+// ImportICS imports the events of the calendar file in the multipart field "file" as
+// activities. The optional "options" field holds the category rules and the date window
+// as JSON (see types.ICSImportOptions). Events imported before are skipped by UID.
+func (h *Handler) ImportICS(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	loc := user.Location()
+
+	var query struct {
+		types.OverlapQuery
+		DryRun bool `form:"dry_run"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid query parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	file, ok := openImportFile(c)
+	if !ok {
+		return
+	}
+	defer file.Close()
+
+	var options types.ICSImportOptions
+	if raw := c.PostForm("options"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &options); err != nil {
+			c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+				"INVALID_INPUT",
+				"Invalid import options",
+				err.Error(),
+			))
+			return
+		}
+	}
+
+	year, month, day := time.Now().In(loc).Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, loc)
+	from, to := today.AddDate(-1, 0, 0), today
+	var err error
+	if options.From != "" {
+		if from, err = time.ParseInLocation("2006-01-02", options.From, loc); err != nil {
+			c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+				"INVALID_INPUT",
+				"Invalid import options",
+				"from must be a date in YYYY-MM-DD format",
+			))
+			return
+		}
+	}
+	if options.To != "" {
+		if to, err = time.ParseInLocation("2006-01-02", options.To, loc); err != nil {
+			c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+				"INVALID_INPUT",
+				"Invalid import options",
+				"to must be a date in YYYY-MM-DD format",
+			))
+			return
+		}
+	}
+	if to.Before(from) {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid import options",
+			"from must not be after to",
+		))
+		return
+	}
+	// The window ends after the last day
+	to = to.AddDate(0, 0, 1)
+
+	categoryIDs := map[uint]bool{}
+	if options.DefaultCategoryID != 0 {
+		categoryIDs[options.DefaultCategoryID] = true
+	}
+	for _, rule := range options.Rules {
+		if strings.TrimSpace(rule.Keyword) == "" || rule.CategoryID == 0 {
+			c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+				"INVALID_INPUT",
+				"Invalid import options",
+				"Every rule needs a keyword and a category_id",
+			))
+			return
+		}
+		categoryIDs[rule.CategoryID] = true
+	}
+	exist, err := categoriesExist(h.db, categoryIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to check categories",
+			err.Error(),
+		))
+		return
+	}
+	if !exist {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid import options",
+			"The rules refer to categories that do not exist",
+		))
+		return
+	}
+
+	events, invalid, err := ical.Read(file, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid import file",
+			err.Error(),
+		))
+		return
+	}
+
+	rows, rowErrors, err := icsImportRows(events, options, from, to)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid import file",
+			err.Error(),
+		))
+		return
+	}
+	for _, event := range invalid {
+		rowErrors = append(rowErrors, types.ImportRowError{Row: event.Line, Message: event.Err.Error()})
+	}
+	sort.SliceStable(rowErrors, func(i, j int) bool { return rowErrors[i].Row < rowErrors[j].Row })
+
+	h.runImport(c, rows, rowErrors, query.DryRun, query.Overlap)
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"dailyact/types"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseImportDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		err   bool
+	}{
+		{value: "0", want: 0},
+		{value: "5400", want: 90 * time.Minute},
+		{value: "1:30", want: 90 * time.Minute},
+		{value: "0:45:30", want: 45*time.Minute + 30*time.Second},
+		{value: "12:00:00", want: 12 * time.Hour},
+		{value: "", err: true},
+		{value: "-60", err: true},
+		{value: "1:-5", err: true},
+		{value: "90m", err: true},
+		{value: "1:2:3:4", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseImportDuration(tt.value)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseImportDuration(%q) = %v, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseImportDuration(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseImportCSV(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mapping := types.ImportMapping{}.WithDefaults()
+
+	tests := []struct {
+		name     string
+		mapping  types.ImportMapping
+		csv      string
+		rows     []int // lines of the rows read
+		starts   []time.Time
+		errors   []types.ImportRowError
+		fileFail bool
+	}{
+		{
+			name:    "export columns",
+			mapping: mapping,
+			csv: "\ufeffstart_time,end_time,description,category,tags,mood\n" +
+				"2024-03-01 09:00,2024-03-01 10:30,Standup,Work,a;b,4\n" +
+				"2024-03-01T12:00:00Z,2024-03-01T12:45:00Z,Lunch,Meals,,\n",
+			rows:   []int{2, 3},
+			starts: []time.Time{time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC), time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "duration column",
+			mapping: types.ImportMapping{StartTime: "from", Duration: "secs", Category: "cat"}.WithDefaults(),
+			csv:     "from,secs,cat\n2024-03-01 09:00,1:30,Work\n",
+			rows:    []int{2},
+			starts:  []time.Time{time.Date(2024, 3, 1, 8, 0, 0, 0, time.UTC)},
+		},
+		{
+			name:    "malformed first field",
+			mapping: mapping,
+			csv: "start_time,end_time,category\n" +
+				"2024-03-01 \"09:00,2024-03-01 10:00,Work\n" +
+				"2024-03-01 11:00,2024-03-01 12:00,Work\n",
+			rows:   []int{3},
+			starts: []time.Time{time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC)},
+			errors: []types.ImportRowError{{Row: 2, Message: `bare " in non-quoted-field`}},
+		},
+		{
+			name:    "unterminated quote",
+			mapping: mapping,
+			csv:     "start_time,end_time,category\n\"2024-03-01 09:00,2024-03-01 10:00,Work\n",
+			errors:  []types.ImportRowError{{Row: 2, Message: `extraneous or missing " in quoted-field`}},
+		},
+		{
+			name:    "invalid cells",
+			mapping: mapping,
+			csv: "start_time,end_time,category,mood\n" +
+				"yesterday,2024-03-01 10:00,Work,\n" +
+				"2024-03-01 10:00,2024-03-01 09:00,Work,\n" +
+				"2024-03-01 09:00,2024-03-01 10:00,,\n" +
+				"2024-03-01 09:00,2024-03-01 10:00,Work,6\n",
+			errors: []types.ImportRowError{
+				{Row: 2, Field: "start_time"},
+				{Row: 3, Field: "end_time"},
+				{Row: 4, Field: "category"},
+				{Row: 5, Field: "mood"},
+			},
+		},
+		{
+			name:     "missing column",
+			mapping:  mapping,
+			csv:      "start_time,category\n2024-03-01 09:00,Work\n",
+			fileFail: true,
+		},
+		{
+			name:     "empty file",
+			mapping:  mapping,
+			csv:      "",
+			fileFail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, rowErrors, err := parseImportCSV(strings.NewReader(tt.csv), tt.mapping, berlin)
+			if tt.fileFail {
+				if err == nil {
+					t.Fatal("want an error for the file")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(rows) != len(tt.rows) {
+				t.Fatalf("got %d rows, want %d", len(rows), len(tt.rows))
+			}
+			for i, row := range rows {
+				if row.Line != tt.rows[i] {
+					t.Errorf("row %d: line %d, want %d", i, row.Line, tt.rows[i])
+				}
+				if !row.StartTime.Equal(tt.starts[i]) {
+					t.Errorf("row %d: start %v, want %v", i, row.StartTime, tt.starts[i])
+				}
+			}
+
+			if len(rowErrors) != len(tt.errors) {
+				t.Fatalf("got row errors %+v, want %+v", rowErrors, tt.errors)
+			}
+			for i, rowErr := range rowErrors {
+				want := tt.errors[i]
+				if rowErr.Row != want.Row || rowErr.Field != want.Field || (want.Message != "" && rowErr.Message != want.Message) {
+					t.Errorf("row error %d: %+v, want %+v", i, rowErr, want)
+				}
+			}
+		})
+	}
+}
+
+func TestParseImportCSVFields(t *testing.T) {
+	csv := "start_time,end_time,description,category,tags,mood,energy\n" +
+		"2024-03-01 09:00,2024-03-01 10:00,'=SUM(A1),Work, Deep ; focus ;deep,3,\n"
+	rows, rowErrors, err := parseImportCSV(strings.NewReader(csv), types.ImportMapping{}.WithDefaults(), time.UTC)
+	if err != nil || len(rowErrors) != 0 || len(rows) != 1 {
+		t.Fatalf("got %+v, %+v, %v", rows, rowErrors, err)
+	}
+	row := rows[0]
+	if row.Description != "=SUM(A1)" {
+		t.Errorf("description %q, want the formula guard removed", row.Description)
+	}
+	if strings.Join(row.Tags, ",") != "deep,focus" {
+		t.Errorf("tags %v, want [deep focus]", row.Tags)
+	}
+	if row.Mood == nil || *row.Mood != 3 || row.Energy != nil {
+		t.Errorf("mood %v and energy %v, want 3 and none", row.Mood, row.Energy)
+	}
+	if row.EndTime.Sub(row.StartTime) != time.Hour {
+		t.Errorf("duration %v, want 1h", row.EndTime.Sub(row.StartTime))
+	}
+}
+
+func TestParseImportCSVRowLimit(t *testing.T) {
+	var csv strings.Builder
+	csv.WriteString("start_time,end_time,category\n")
+	for i := 0; i < maxImportRows; i++ {
+		csv.WriteString("a\"b,,\n")
+	}
+	if _, rowErrors, err := parseImportCSV(strings.NewReader(csv.String()), types.ImportMapping{}.WithDefaults(), time.UTC); err != nil {
+		t.Fatalf("%d rows: %v", maxImportRows, err)
+	} else if len(rowErrors) == 0 {
+		t.Fatal("want row errors for the malformed rows")
+	}
+
+	csv.WriteString("2024-03-01 09:00,2024-03-01 10:00,Work\n")
+	if _, _, err := parseImportCSV(strings.NewReader(csv.String()), types.ImportMapping{}.WithDefaults(), time.UTC); err == nil {
+		t.Fatalf("%d rows: want an error", maxImportRows+1)
+	}
+}
@@ -0,0 +1,539 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"dailyact/models"
+	"dailyact/types"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// trackerSources are the time trackers whose exports can be imported
+var trackerSources = map[string]bool{"toggl": true, "clockify": true}
+
+// trackerEntry is a time entry read from a Toggl or Clockify export
+type trackerEntry struct {
+	Line        int
+	ExternalID  string
+	StartTime   time.Time
+	EndTime     time.Time
+	Description string
+	Project     string
+	Tags        []string
+}
+
+// trackerDateFormats and trackerClockFormats are the date and time layouts of the CSV
+// exports. Slashed dates are read month first, dotted dates day first.
+var (
+	trackerDateFormats  = []string{"2006-01-02", "1/2/2006", "2.1.2006"}
+	trackerClockFormats = []string{"15:04:05", "15:04", "3:04:05 PM", "3:04 PM", "3:04:05PM", "3:04PM"}
+)
+
+// parseTrackerTime reads the separate date and time cells of a CSV export in loc
+func parseTrackerTime(date, clock string, loc *time.Location) (time.Time, error) {
+	for _, dateFormat := range trackerDateFormats {
+		for _, clockFormat := range trackerClockFormats {
+			if parsed, err := time.ParseInLocation(dateFormat+" "+clockFormat, date+" "+clock, loc); err == nil {
+				return parsed, nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q as a date and time", date+" "+clock)
+}
+
+// trackerFingerprint identifies an entry of a CSV export, which carries no entry IDs
+func trackerFingerprint(entry trackerEntry) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		entry.StartTime.UTC().Format(time.RFC3339),
+		entry.EndTime.UTC().Format(time.RFC3339),
+		entry.Description,
+		entry.Project,
+	}, "\x00")))
+	return "csv-" + hex.EncodeToString(sum[:16])
+}
+
+// splitTrackerTags splits the comma separated tags of a CSV export
+func splitTrackerTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseTrackerCSV reads the detailed CSV export of Toggl or Clockify. Both name their
+// columns Description, Project, Tags, Start Date, Start Time, End Date and End Time.
+func parseTrackerCSV(r io.Reader, loc *time.Location) ([]trackerEntry, []types.ImportRowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot read the CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		if i == 0 {
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"start date", "start time", "end date", "end time"} {
+		if _, ok := columns[name]; !ok {
+			return nil, nil, fmt.Errorf("column %q not found in the CSV header", name)
+		}
+	}
+
+	var entries []trackerEntry
+	var rowErrors []types.ImportRowError
+	records := csvRecords{reader: reader}
+	for {
+		record, line, err := records.next(&rowErrors)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+
+		get := func(column string) string {
+			i, ok := columns[column]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		entry := trackerEntry{
+			Line:        line,
+			Description: get("description"),
+			Project:     get("project"),
+			Tags:        splitTrackerTags(get("tags")),
+		}
+		if entry.StartTime, err = parseTrackerTime(get("start date"), get("start time"), loc); err != nil {
+			rowErrors = append(rowErrors, types.ImportRowError{Row: line, Field: "start_time", Message: err.Error()})
+			continue
+		}
+		if get("end date") == "" && get("end time") == "" {
+			rowErrors = append(rowErrors, types.ImportRowError{Row: line, Field: "end_time", Message: "entry is still running"})
+			continue
+		}
+		if entry.EndTime, err = parseTrackerTime(get("end date"), get("end time"), loc); err != nil {
+			rowErrors = append(rowErrors, types.ImportRowError{Row: line, Field: "end_time", Message: err.Error()})
+			continue
+		}
+		entry.ExternalID = trackerFingerprint(entry)
+		entries = append(entries, entry)
+	}
+	return entries, rowErrors, nil
+}
+
+// trackerJSONEntry holds the fields of a time entry in the JSON exports of Toggl (API and
+// reports) and Clockify. Fields of the same meaning differ in name between them.
+type trackerJSONEntry struct {
+	ID               json.RawMessage   `json:"id"`
+	MongoID          json.RawMessage   `json:"_id"`
+	Description      string            `json:"description"`
+	Start            string            `json:"start"`
+	Stop             string            `json:"stop"`
+	End              string            `json:"end"`
+	Project          json.RawMessage   `json:"project"` // a name, or an object with a name
+	ProjectName      string            `json:"project_name"`
+	ProjectNameCamel string            `json:"projectName"`
+	Tags             []json.RawMessage `json:"tags"` // names, or objects with a name
+	TimeInterval     *struct {
+		Start string `json:"start"`
+		End   string `json:"end"`
+	} `json:"timeInterval"`
+}
+
+// jsonName reads a value that is either a string or an object with a name
+func jsonName(raw json.RawMessage) string {
+	var name string
+	if json.Unmarshal(raw, &name) == nil {
+		return name
+	}
+	var object struct {
+		Name string `json:"name"`
+	}
+	if json.Unmarshal(raw, &object) == nil {
+		return object.Name
+	}
+	return ""
+}
+
+// jsonID reads an ID given either as a number or as a string
+func jsonID(raw json.RawMessage) string {
+	value := strings.Trim(strings.TrimSpace(string(raw)), `"`)
+	if value == "null" {
+		return ""
+	}
+	return value
+}
+
+// parseTrackerJSON reads a JSON export of Toggl or Clockify, either a list of time entries
+// or an object holding them under data, time_entries or timeentries. Rows are counted
+// from 1 in the order of the list.
+func parseTrackerJSON(data []byte) ([]trackerEntry, []types.ImportRowError, error) {
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		var object map[string]json.RawMessage
+		if json.Unmarshal(data, &object) != nil {
+			return nil, nil, fmt.Errorf("cannot read the JSON export: %w", err)
+		}
+		for _, key := range []string{"data", "time_entries", "timeentries", "timeEntries"} {
+			if raw, ok := object[key]; ok {
+				if err := json.Unmarshal(raw, &list); err != nil {
+					return nil, nil, fmt.Errorf("cannot read the JSON export: %w", err)
+				}
+				break
+			}
+		}
+		if list == nil {
+			return nil, nil, errors.New("no time entries found in the JSON export")
+		}
+	}
+	if len(list) > maxImportRows {
+		return nil, nil, fmt.Errorf("an import can hold at most %d rows", maxImportRows)
+	}
+
+	var entries []trackerEntry
+	var rowErrors []types.ImportRowError
+	for i, raw := range list {
+		row := i + 1
+		var item trackerJSONEntry
+		if err := json.Unmarshal(raw, &item); err != nil {
+			rowErrors = append(rowErrors, types.ImportRowError{Row: row, Message: err.Error()})
+			continue
+		}
+
+		start, end := item.Start, item.Stop
+		if end == "" {
+			end = item.End
+		}
+		if item.TimeInterval != nil {
+			start, end = item.TimeInterval.Start, item.TimeInterval.End
+		}
+
+		entry := trackerEntry{
+			Line:        row,
+			ExternalID:  jsonID(item.ID),
+			Description: strings.TrimSpace(item.Description),
+			Project:     item.ProjectName,
+		}
+		if entry.ExternalID == "" {
+			entry.ExternalID = jsonID(item.MongoID)
+		}
+		if entry.Project == "" {
+			entry.Project = item.ProjectNameCamel
+		}
+		if entry.Project == "" && item.Project != nil {
+			entry.Project = jsonName(item.Project)
+		}
+		entry.Project = strings.TrimSpace(entry.Project)
+		for _, tag := range item.Tags {
+			if name := strings.TrimSpace(jsonName(tag)); name != "" {
+				entry.Tags = append(entry.Tags, name)
+			}
+		}
+
+		var err error
+		if entry.StartTime, err = time.Parse(time.RFC3339, start); err != nil {
+			rowErrors = append(rowErrors, types.ImportRowError{Row: row, Field: "start_time", Message: fmt.Sprintf("cannot parse %q as a time", start)})
+			continue
+		}
+		if end == "" {
+			rowErrors = append(rowErrors, types.ImportRowError{Row: row, Field: "end_time", Message: "entry is still running"})
+			continue
+		}
+		if entry.EndTime, err = time.Parse(time.RFC3339, end); err != nil {
+			rowErrors = append(rowErrors, types.ImportRowError{Row: row, Field: "end_time", Message: fmt.Sprintf("cannot parse %q as a time", end)})
+			continue
+		}
+		if entry.ExternalID == "" {
+			entry.ExternalID = trackerFingerprint(entry)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rowErrors, nil
+}
+
+// readTrackerExport reads the uploaded export of the source named in the path, telling
+// CSV and JSON apart by their content. It writes the error response when it fails.
+func readTrackerExport(c *gin.Context) (source, format string, entries []trackerEntry, rowErrors []types.ImportRowError, ok bool) {
+	user := c.MustGet("user").(models.User)
+
+	source = c.Param("source")
+	if !trackerSources[source] {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Unknown import source",
+			fmt.Sprintf("%q is not a supported time tracker, use toggl or clockify", source),
+		))
+		return "", "", nil, nil, false
+	}
+
+	file, ok := openImportFile(c)
+	if !ok {
+		return "", "", nil, nil, false
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Failed to read import file",
+			err.Error(),
+		))
+		return "", "", nil, nil, false
+	}
+
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\ufeff")), " \t\r\n")
+	if len(trimmed) > 0 && (trimmed[0] == '[' || trimmed[0] == '{') {
+		format = "json"
+		entries, rowErrors, err = parseTrackerJSON(trimmed)
+	} else {
+		format = "csv"
+		entries, rowErrors, err = parseTrackerCSV(bytes.NewReader(data), user.Location())
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid import file",
+			err.Error(),
+		))
+		return "", "", nil, nil, false
+	}
+	valid := entries[:0]
+	for _, entry := range entries {
+		if entry.EndTime.Before(entry.StartTime) {
+			rowErrors = append(rowErrors, types.ImportRowError{Row: entry.Line, Field: "end_time", Message: models.ErrNegativeDuration.Error()})
+			continue
+		}
+		valid = append(valid, entry)
+	}
+	return source, format, valid, rowErrors, true
+}
+
+// PreviewTrackerImport lists the projects and tags of a Toggl or Clockify export with a
+// suggested category for each, so the user can confirm the mapping before importing
+func (h *Handler) PreviewTrackerImport(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	source, format, entries, rowErrors, ok := readTrackerExport(c)
+	if !ok {
+		return
+	}
+
+	var categories []models.Category
+	if err := h.db.Select("id", "name").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch categories",
+			err.Error(),
+		))
+		return
+	}
+	categoryIDs := map[string]uint{}
+	for _, category := range categories {
+		categoryIDs[strings.ToLower(category.Name)] = category.ID
+	}
+
+	externalIDs := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		externalIDs = append(externalIDs, entry.ExternalID)
+	}
+	existing, err := existingExternalIDs(h.db, user.ID, source, externalIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to check for imported entries",
+			err.Error(),
+		))
+		return
+	}
+
+	projects := map[string]*types.TrackerMappingEntry{}
+	tags := map[string]*types.TrackerMappingEntry{}
+	count := func(groups map[string]*types.TrackerMappingEntry, name string, duration int) {
+		group, ok := groups[name]
+		if !ok {
+			group = &types.TrackerMappingEntry{Name: name}
+			if id, found := categoryIDs[strings.ToLower(name)]; found && name != "" {
+				group.SuggestedCategoryID = &id
+			}
+			groups[name] = group
+		}
+		group.Entries++
+		group.Duration += duration
+	}
+
+	preview := types.TrackerImportPreview{
+		Source:  source,
+		Format:  format,
+		Entries: len(entries),
+		Errors:  rowErrors,
+	}
+	for _, entry := range entries {
+		if existing[entry.ExternalID] {
+			preview.Duplicates++
+		}
+		duration := int(entry.EndTime.Sub(entry.StartTime).Seconds())
+		count(projects, entry.Project, duration)
+		for _, tag := range entry.Tags {
+			count(tags, tag, duration)
+		}
+	}
+	preview.Projects = sortedMappingEntries(projects)
+	preview.Tags = sortedMappingEntries(tags)
+	if preview.Errors == nil {
+		preview.Errors = []types.ImportRowError{}
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse("Import preview retrieved successfully", preview, nil))
+}
+
+// sortedMappingEntries lists the groups by name
+func sortedMappingEntries(groups map[string]*types.TrackerMappingEntry) []types.TrackerMappingEntry {
+	list := make([]types.TrackerMappingEntry, 0, len(groups))
+	for _, group := range groups {
+		list = append(list, *group)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// trackerImportRows turns the entries into import rows, choosing each category with the
+// mapping. Entries left without a category are reported as row errors.
+func trackerImportRows(source string, entries []trackerEntry, mapping types.TrackerImportMapping) ([]importRow, []types.ImportRowError) {
+	var rows []importRow
+	var rowErrors []types.ImportRowError
+	for _, entry := range entries {
+		categoryID := mapping.DefaultCategoryID
+		if mapping.MapBy == "tag" {
+			for _, tag := range entry.Tags {
+				if id, ok := mapping.Categories[tag]; ok {
+					categoryID = id
+					break
+				}
+			}
+		} else if id, ok := mapping.Categories[entry.Project]; ok {
+			categoryID = id
+		}
+		if categoryID == 0 {
+			message := fmt.Sprintf("no category mapped for project %q", entry.Project)
+			if mapping.MapBy == "tag" {
+				message = fmt.Sprintf("no category mapped for tags %q", entry.Tags)
+			}
+			rowErrors = append(rowErrors, types.ImportRowError{Row: entry.Line, Field: "category", Message: message})
+			continue
+		}
+
+		tags, err := normalizeTagNames(entry.Tags)
+		if err != nil {
+			rowErrors = append(rowErrors, types.ImportRowError{Row: entry.Line, Field: "tags", Message: err.Error()})
+			continue
+		}
+
+		rows = append(rows, importRow{
+			Line:           entry.Line,
+			StartTime:      entry.StartTime,
+			EndTime:        entry.EndTime,
+			Description:    entry.Description,
+			CategoryID:     categoryID,
+			Tags:           tags,
+			ExternalSource: source,
+			ExternalID:     entry.ExternalID,
+		})
+	}
+	return rows, rowErrors
+}
+
+// ImportTrackerExport imports a Toggl or Clockify export from the multipart field "file",
+// with the confirmed category mapping as JSON in the field "mapping" (see
+// types.TrackerImportMapping). Entries imported before are skipped.
+func (h *Handler) ImportTrackerExport(c *gin.Context) {
+	var query struct {
+		types.OverlapQuery
+		DryRun bool `form:"dry_run"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid query parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	source, _, entries, rowErrors, ok := readTrackerExport(c)
+	if !ok {
+		return
+	}
+
+	var mapping types.TrackerImportMapping
+	if err := json.Unmarshal([]byte(c.PostForm("mapping")), &mapping); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid category mapping",
+			err.Error(),
+		))
+		return
+	}
+	if mapping.MapBy == "" {
+		mapping.MapBy = "project"
+	}
+	if mapping.MapBy != "project" && mapping.MapBy != "tag" {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid category mapping",
+			"map_by must be project or tag",
+		))
+		return
+	}
+
+	categoryIDs := map[uint]bool{}
+	if mapping.DefaultCategoryID != 0 {
+		categoryIDs[mapping.DefaultCategoryID] = true
+	}
+	for _, id := range mapping.Categories {
+		categoryIDs[id] = true
+	}
+	exist, err := categoriesExist(h.db, categoryIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to check categories",
+			err.Error(),
+		))
+		return
+	}
+	if !exist {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid category mapping",
+			"The mapping refers to categories that do not exist",
+		))
+		return
+	}
+
+	rows, mappingErrors := trackerImportRows(source, entries, mapping)
+	rowErrors = append(rowErrors, mappingErrors...)
+	sort.SliceStable(rowErrors, func(i, j int) bool { return rowErrors[i].Row < rowErrors[j].Row })
+
+	h.runImport(c, rows, rowErrors, query.DryRun, query.Overlap)
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTrackerTime(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		date, clock string
+		want        time.Time
+		err         bool
+	}{
+		{date: "2024-03-01", clock: "09:15:00", want: time.Date(2024, 3, 1, 9, 15, 0, 0, berlin)},
+		{date: "2024-03-01", clock: "09:15", want: time.Date(2024, 3, 1, 9, 15, 0, 0, berlin)},
+		{date: "3/1/2024", clock: "9:15 PM", want: time.Date(2024, 3, 1, 21, 15, 0, 0, berlin)},
+		{date: "3/1/2024", clock: "12:05:30AM", want: time.Date(2024, 3, 1, 0, 5, 30, 0, berlin)},
+		{date: "1.3.2024", clock: "21:15", want: time.Date(2024, 3, 1, 21, 15, 0, 0, berlin)},
+		{date: "2024-03-31", clock: "03:30", want: time.Date(2024, 3, 31, 1, 30, 0, 0, time.UTC)}, // first hour of summer time
+		{date: "2024-03-01", clock: "", err: true},
+		{date: "", clock: "09:15", err: true},
+		{date: "2024-13-01", clock: "09:15", err: true},
+		{date: "2024-03-01", clock: "25:00", err: true},
+	}
+	for _, tt := range tests {
+		got, err := parseTrackerTime(tt.date, tt.clock, berlin)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseTrackerTime(%q, %q) = %v, want an error", tt.date, tt.clock, got)
+			}
+			continue
+		}
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseTrackerTime(%q, %q) = %v, %v, want %v", tt.date, tt.clock, got, err, tt.want)
+		}
+	}
+}
+
+func TestParseTrackerCSV(t *testing.T) {
+	tests := []struct {
+		name     string
+		csv      string
+		lines    []int
+		errors   []int // rows with a row error
+		fileFail bool
+	}{
+		{
+			name: "toggl",
+			csv: "\ufeffUser,Email,Project,Description,Billable,Start date,Start time,End date,End time,Duration,Tags\n" +
+				"Ann,ann@example.com,Work,Standup,No,2024-03-01,09:00:00,2024-03-01,09:15:00,00:15:00,\"meeting, daily\"\n" +
+				"Ann,ann@example.com,,Reading,No,2024-03-01,20:00:00,2024-03-01,21:00:00,01:00:00,\n",
+			lines: []int{2, 3},
+		},
+		{
+			name: "clockify",
+			csv: "Project,Client,Description,Task,User,Tags,Billable,Start Date,Start Time,End Date,End Time\n" +
+				"Work,,Review,,Ann,,No,03/01/2024,01:00 PM,03/01/2024,02:30 PM\n",
+			lines: []int{2},
+		},
+		{
+			name: "malformed first field",
+			csv: "Start date,Start time,End date,End time\n" +
+				"2024-03-01 \",09:00,2024-03-01,10:00\n" +
+				"2024-03-01,11:00,2024-03-01,12:00\n",
+			lines:  []int{3},
+			errors: []int{2},
+		},
+		{
+			name: "running and invalid entries",
+			csv: "Start date,Start time,End date,End time\n" +
+				"2024-03-01,09:00,,\n" +
+				"someday,09:00,2024-03-01,10:00\n" +
+				"2024-03-01,09:00,2024-03-01,later\n",
+			errors: []int{2, 3, 4},
+		},
+		{
+			name:     "missing column",
+			csv:      "Start date,Start time,End date\n2024-03-01,09:00,2024-03-01\n",
+			fileFail: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, rowErrors, err := parseTrackerCSV(strings.NewReader(tt.csv), time.UTC)
+			if tt.fileFail {
+				if err == nil {
+					t.Fatal("want an error for the file")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.lines) {
+				t.Fatalf("got %d entries, want %d", len(entries), len(tt.lines))
+			}
+			for i, entry := range entries {
+				if entry.Line != tt.lines[i] {
+					t.Errorf("entry %d: line %d, want %d", i, entry.Line, tt.lines[i])
+				}
+				if !strings.HasPrefix(entry.ExternalID, "csv-") {
+					t.Errorf("entry %d: external ID %q, want a fingerprint", i, entry.ExternalID)
+				}
+			}
+			if len(rowErrors) != len(tt.errors) {
+				t.Fatalf("got row errors %+v, want rows %v", rowErrors, tt.errors)
+			}
+			for i, rowErr := range rowErrors {
+				if rowErr.Row != tt.errors[i] {
+					t.Errorf("row error %d: row %d, want %d", i, rowErr.Row, tt.errors[i])
+				}
+			}
+		})
+	}
+}
+
+func TestParseTrackerCSVFields(t *testing.T) {
+	csv := "Project,Description,Tags,Start date,Start time,End date,End time\n" +
+		" Work , Standup ,\"meeting, , daily\",2024-03-01,23:30,2024-03-02,00:15\n"
+	entries, rowErrors, err := parseTrackerCSV(strings.NewReader(csv), time.UTC)
+	if err != nil || len(rowErrors) != 0 || len(entries) != 1 {
+		t.Fatalf("got %+v, %+v, %v", entries, rowErrors, err)
+	}
+	entry := entries[0]
+	if entry.Project != "Work" || entry.Description != "Standup" {
+		t.Errorf("project %q and description %q, want Work and Standup", entry.Project, entry.Description)
+	}
+	if strings.Join(entry.Tags, "|") != "meeting|daily" {
+		t.Errorf("tags %v, want [meeting daily]", entry.Tags)
+	}
+	if entry.EndTime.Sub(entry.StartTime) != 45*time.Minute {
+		t.Errorf("duration %v, want 45m across midnight", entry.EndTime.Sub(entry.StartTime))
+	}
+
+	again, _, _ := parseTrackerCSV(strings.NewReader(csv), time.UTC)
+	if again[0].ExternalID != entry.ExternalID {
+		t.Error("the same entry read twice has different fingerprints")
+	}
+}
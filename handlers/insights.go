@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/types"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dayRatingRow is the average activity rating of one local day
+type dayRatingRow struct {
+	Date   time.Time
+	Mood   *float64
+	Energy *float64
+}
+
+// ratingAverage accumulates ratings into an average
+type ratingAverage struct {
+	sum   float64
+	count int
+}
+
+func (a *ratingAverage) add(rating *float64) {
+	if rating != nil {
+		a.sum += *rating
+		a.count++
+	}
+}
+
+// value returns the average rounded to two decimals, nil without ratings
+func (a ratingAverage) value() *float64 {
+	if a.count == 0 {
+		return nil
+	}
+	average := math.Round(a.sum/float64(a.count)*100) / 100
+	return &average
+}
+
+// compareRatings builds the comparison of two averages
+func compareRatings(with, without ratingAverage) types.RatingComparison {
+	comparison := types.RatingComparison{
+		AverageWith:    with.value(),
+		AverageWithout: without.value(),
+	}
+	if comparison.AverageWith != nil && comparison.AverageWithout != nil {
+		difference := math.Round((*comparison.AverageWith-*comparison.AverageWithout)*100) / 100
+		comparison.Difference = &difference
+	}
+	return comparison
+}
+
+// GetMoodInsights compares, per category, the mood and energy of days with at least
+// min_minutes spent on the category against the other rated days. A day's rating is
+// its check-in, or the average rating of its activities when there is no check-in.
+func (h *Handler) GetMoodInsights(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	loc := user.Location()
+
+	var query struct {
+		StartDate  *string `form:"start_date"` // YYYY-MM-DD, defaults to 89 days before end_date
+		EndDate    *string `form:"end_date"`   // YYYY-MM-DD, defaults to today
+		MinMinutes int     `form:"min_minutes,default=60" binding:"min=1"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid query parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	// Days are kept at midnight UTC, like activity dates
+	endDay := models.LocalDate(time.Now(), loc)
+	if query.EndDate != nil {
+		day, err := types.ParseDay(*query.EndDate, time.UTC)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+				"INVALID_QUERY",
+				"Invalid query parameters",
+				"end_date must be formatted as YYYY-MM-DD",
+			))
+			return
+		}
+		endDay = day
+	}
+	startDay := endDay.AddDate(0, 0, -89)
+	if query.StartDate != nil {
+		day, err := types.ParseDay(*query.StartDate, time.UTC)
+		if err != nil || day.After(endDay) {
+			c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+				"INVALID_QUERY",
+				"Invalid query parameters",
+				"start_date must be formatted as YYYY-MM-DD and not be after end_date",
+			))
+			return
+		}
+		startDay = day
+	}
+	nextDay := endDay.AddDate(0, 0, 1)
+
+	// Ratings of the activities of each day
+	var activityRatings []dayRatingRow
+	if err := h.db.Model(&models.Activity{}).
+		Select("date, AVG(mood)::float AS mood, AVG(energy)::float AS energy").
+		Where("user_id = ? AND date >= ? AND date < ? AND pending = false", user.ID, startDay, nextDay).
+		Where("mood IS NOT NULL OR energy IS NOT NULL").
+		Group("date").
+		Scan(&activityRatings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to compute insights",
+			err.Error(),
+		))
+		return
+	}
+
+	var checkIns []models.CheckIn
+	if err := h.db.Where("user_id = ? AND date >= ? AND date < ?", user.ID, startDay, nextDay).Find(&checkIns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch check-ins",
+			err.Error(),
+		))
+		return
+	}
+
+	// Check-ins take precedence over the activity ratings of the same day
+	ratings := map[time.Time]dayRatingRow{}
+	for _, row := range activityRatings {
+		row.Date = time.Date(row.Date.Year(), row.Date.Month(), row.Date.Day(), 0, 0, 0, 0, time.UTC)
+		ratings[row.Date] = row
+	}
+	for _, checkIn := range checkIns {
+		day := time.Date(checkIn.Date.Year(), checkIn.Date.Month(), checkIn.Date.Day(), 0, 0, 0, 0, time.UTC)
+		row := ratings[day]
+		mood := float64(checkIn.Mood)
+		row.Mood = &mood
+		if checkIn.Energy != nil {
+			energy := float64(*checkIn.Energy)
+			row.Energy = &energy
+		}
+		ratings[day] = row
+	}
+
+	// Time per category and local day, activities crossing midnight count for both days
+	activities := h.db.Model(&models.Activity{}).
+		Where("activities.user_id = ? AND activities.pending = false", user.ID).
+		Where("activities.start_time < ? AND COALESCE(activities.end_time, NOW()) > ?",
+			time.Date(nextDay.Year(), nextDay.Month(), nextDay.Day(), 0, 0, 0, 0, loc),
+			time.Date(startDay.Year(), startDay.Month(), startDay.Day(), 0, 0, 0, 0, loc))
+
+	var totals []dailyTotalRow
+	if err := h.db.Table("(?) AS s", daySlicesQuery(h.db, activities, loc.String())).
+		Joins("JOIN categories ON categories.id = s.category_id").
+		Select("s.category_id, categories.name AS category_name, s.local_day, SUM(s.seconds)::bigint AS total_seconds").
+		Where("s.seconds > 0 AND s.local_day >= ?::date AND s.local_day <= ?::date",
+			startDay.Format("2006-01-02"), endDay.Format("2006-01-02")).
+		Group("s.category_id, categories.name, s.local_day").
+		Order("categories.name").
+		Scan(&totals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to compute insights",
+			err.Error(),
+		))
+		return
+	}
+
+	// Days on which each category reached the threshold
+	threshold := int64(query.MinMinutes) * 60
+	insights := []types.CategoryMoodInsight{}
+	reached := map[uint]map[time.Time]bool{}
+	for _, row := range totals {
+		if reached[row.CategoryID] == nil {
+			reached[row.CategoryID] = map[time.Time]bool{}
+			insights = append(insights, types.CategoryMoodInsight{
+				CategoryID:   row.CategoryID,
+				CategoryName: row.CategoryName,
+			})
+		}
+		if row.TotalSeconds >= threshold {
+			day := time.Date(row.LocalDay.Year(), row.LocalDay.Month(), row.LocalDay.Day(), 0, 0, 0, 0, time.UTC)
+			reached[row.CategoryID][day] = true
+		}
+	}
+
+	for i := range insights {
+		var moodWith, moodWithout, energyWith, energyWithout ratingAverage
+		for day, rating := range ratings {
+			if reached[insights[i].CategoryID][day] {
+				insights[i].DaysWith++
+				moodWith.add(rating.Mood)
+				energyWith.add(rating.Energy)
+			} else {
+				insights[i].DaysWithout++
+				moodWithout.add(rating.Mood)
+				energyWithout.add(rating.Energy)
+			}
+		}
+		insights[i].Mood = compareRatings(moodWith, moodWithout)
+		insights[i].Energy = compareRatings(energyWith, energyWithout)
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Mood insights retrieved successfully",
+		types.MoodInsights{
+			StartDate:  startDay.Format("2006-01-02"),
+			EndDate:    endDay.Format("2006-01-02"),
+			MinMinutes: query.MinMinutes,
+			RatedDays:  len(ratings),
+			Categories: insights,
+		},
+		nil,
+	))
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/types"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// overlapError lists the activities that conflict with the one being saved
+type overlapError struct {
+	ActivityIDs []uint
+}
+
+func (e *overlapError) Error() string {
+	return fmt.Sprintf("activity overlaps %d other activities", len(e.ActivityIDs))
+}
+
+// resolveOverlaps applies the overlap policy to the user's activities intersecting
+// [start, end). A nil end means the activity is running and is treated as ending now.
+// Pending activities from recurring templates are left alone, they are checked once
+// confirmed. It must run inside a transaction that holds the user lock (see lockUser).
+func (h *Handler) resolveOverlaps(tx *gorm.DB, userID, excludeID uint, start time.Time, end *time.Time, policy string) error {
+	effectiveEnd := time.Now()
+	if end != nil {
+		effectiveEnd = *end
+	}
+
+	var conflicts []models.Activity
+	if err := tx.Where("user_id = ? AND id <> ? AND pending = false", userID, excludeID).
+		Where("start_time < ? AND COALESCE(end_time, NOW()) > ?", effectiveEnd, start).
+		Order("start_time").
+		Find(&conflicts).Error; err != nil {
+		return err
+	}
+
+	if len(conflicts) == 0 {
+		return nil
+	}
+
+	if policy == types.OverlapReject || policy == "" {
+		ids := make([]uint, len(conflicts))
+		for i, conflict := range conflicts {
+			ids[i] = conflict.ID
+		}
+		return &overlapError{ActivityIDs: ids}
+	}
+
+	// Activities fully covered by the new one cannot be trimmed without deleting them
+	var covered []uint
+	for _, conflict := range conflicts {
+		if !conflict.StartTime.Before(start) && conflict.EndTime != nil && !conflict.EndTime.After(effectiveEnd) {
+			covered = append(covered, conflict.ID)
+		}
+	}
+	if len(covered) > 0 {
+		return &overlapError{ActivityIDs: covered}
+	}
+
+	for _, conflict := range conflicts {
+		before, err := loadActivityState(tx, conflict.ID)
+		if err != nil {
+			return err
+		}
+
+		originalEnd := conflict.EndTime
+		encloses := false
+
+		if conflict.StartTime.Before(start) {
+			// Cut the tail of an activity that started earlier
+			cut := start
+			conflict.EndTime = &cut
+			encloses = originalEnd == nil || originalEnd.After(effectiveEnd)
+		} else {
+			// Move the start of an activity that began during the new one
+			conflict.StartTime = effectiveEnd
+		}
+
+		if err := tx.Model(&conflict).
+			Select("date", "start_time", "end_time", "duration", "updated_at").
+			Updates(&conflict).Error; err != nil {
+			return err
+		}
+
+		// Adjustments made by the overlap policy are attributed to the activity's owner
+		if err := h.recordRevision(tx, before, userID); err != nil {
+			return err
+		}
+
+		// The remainder is created after the update so a running timer moves over to it
+		if policy == types.OverlapSplit && encloses {
+			if err := h.createSplitRemainder(tx, conflict, effectiveEnd, originalEnd); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// createSplitRemainder stores the part of a split activity that comes after the new one
+func (h *Handler) createSplitRemainder(tx *gorm.DB, original models.Activity, start time.Time, end *time.Time) error {
+	// The loaded activity holds decrypted values, encrypt them again for the copy
+	descriptionEncrypted, err := h.encryptionService.Encrypt(original.Description.String())
+	if err != nil {
+		return err
+	}
+	notesEncrypted, err := h.encryptionService.Encrypt(original.Notes.String())
+	if err != nil {
+		return err
+	}
+
+	remainder := models.Activity{
+		StartTime:   start,
+		EndTime:     end,
+		Description: models.EncryptedString(descriptionEncrypted),
+		Notes:       models.EncryptedString(notesEncrypted),
+		Mood:        original.Mood,
+		Energy:      original.Energy,
+		CategoryID:  original.CategoryID,
+		UserID:      original.UserID,
+	}
+	if err := tx.Create(&remainder).Error; err != nil {
+		return err
+	}
+
+	// The remainder keeps the tags and search tokens of the original activity
+	if err := tx.Exec(
+		"INSERT INTO activity_tags (activity_id, tag_id) SELECT ?, tag_id FROM activity_tags WHERE activity_id = ?",
+		remainder.ID, original.ID,
+	).Error; err != nil {
+		return err
+	}
+	return tx.Exec(
+		"INSERT INTO activity_search_tokens (activity_id, token) SELECT ?, token FROM activity_search_tokens WHERE activity_id = ?",
+		remainder.ID, original.ID,
+	).Error
+}
+
+// saveErrorResponse returns the status and response for an error returned while saving an activity
+func saveErrorResponse(err error, message string) (int, types.Response) {
+	var overlap *overlapError
+	if errors.As(err, &overlap) {
+		return http.StatusConflict, types.NewErrorResponseWithMeta(
+			"ACTIVITY_OVERLAP",
+			"Activity overlaps existing activities",
+			overlap.Error(),
+			gin.H{"conflicting_ids": overlap.ActivityIDs},
+		)
+	}
+
+	if errors.Is(err, models.ErrActivityRunning) {
+		return http.StatusConflict, types.NewErrorResponse(
+			"TIMER_ALREADY_RUNNING",
+			"Another activity is already running",
+			"Stop the running activity before starting a new one",
+		)
+	}
+
+	return http.StatusInternalServerError, types.NewErrorResponse(
+		"DB_ERROR",
+		message,
+		err.Error(),
+	)
+}
+
+// respondSaveError writes the response for an error returned while saving an activity
+func respondSaveError(c *gin.Context, err error, message string) {
+	c.JSON(saveErrorResponse(err, message))
+}
@@ -0,0 +1,350 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/quickentry"
+	"dailyact/types"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxParseAlternatives caps the other time readings and the other categories proposed
+const maxParseAlternatives = 3
+
+// categoryHints maps words of quick entries to words of the category names they suggest
+var categoryHints = map[string][]string{
+	"sleep":     {"sleep", "rest"},
+	"slept":     {"sleep", "rest"},
+	"nap":       {"sleep", "rest"},
+	"gym":       {"exercise", "fitness", "sport", "workout", "health"},
+	"run":       {"exercise", "fitness", "sport", "workout", "health"},
+	"ran":       {"exercise", "fitness", "sport", "workout", "health"},
+	"workout":   {"exercise", "fitness", "sport", "workout", "health"},
+	"yoga":      {"exercise", "fitness", "sport", "workout", "health"},
+	"swim":      {"exercise", "fitness", "sport", "workout", "health"},
+	"walk":      {"exercise", "fitness", "sport", "health"},
+	"bike":      {"exercise", "fitness", "sport", "commute"},
+	"breakfast": {"meal", "food", "eat"},
+	"lunch":     {"meal", "food", "eat"},
+	"dinner":    {"meal", "food", "eat"},
+	"meeting":   {"work", "meeting"},
+	"call":      {"work", "meeting", "social"},
+	"standup":   {"work", "meeting"},
+	"email":     {"work"},
+	"code":      {"work", "development"},
+	"read":      {"read", "study", "learn", "education"},
+	"study":     {"study", "learn", "education"},
+	"class":     {"study", "learn", "education"},
+	"course":    {"study", "learn", "education"},
+	"commute":   {"commute", "travel", "transport"},
+	"drive":     {"commute", "travel", "transport"},
+	"train":     {"commute", "travel", "transport"},
+	"bus":       {"commute", "travel", "transport"},
+	"clean":     {"chore", "household", "home"},
+	"laundry":   {"chore", "household", "home"},
+	"cook":      {"chore", "household", "home", "meal", "food"},
+	"shop":      {"chore", "errand", "shopping"},
+	"tv":        {"leisure", "entertainment", "relax"},
+	"movie":     {"leisure", "entertainment", "relax"},
+	"game":      {"leisure", "entertainment", "relax"},
+	"coffee":    {"social", "break", "leisure"},
+	"friend":    {"social", "family"},
+}
+
+// stem drops a plural, -ing or -ed ending from a lowercase word
+func stem(word string) string {
+	for _, suffix := range []string{"ing", "ed", "s"} {
+		if suffix == "s" && (strings.HasSuffix(word, "ss") || strings.HasSuffix(word, "us")) {
+			break
+		}
+		if trimmed := strings.TrimSuffix(word, suffix); trimmed != word && len(trimmed) >= 3 {
+			return trimmed
+		}
+	}
+	return word
+}
+
+// lowerWords returns the lowercased runs of letters and digits of a text
+func lowerWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// categoryGuess is a category a quick entry may belong to
+type categoryGuess struct {
+	category   models.Category
+	score      float64
+	confidence float64
+}
+
+// categoryCount is the number of activities a user has in a category
+type categoryCount struct {
+	CategoryID uint
+	Count      int64
+}
+
+// categoryCounts counts the user's activities of the past year by category, only those
+// containing one of the search tokens unless tokens is nil
+func (h *Handler) categoryCounts(userID uint, tokens []string) ([]categoryCount, error) {
+	query := h.db.Model(&models.Activity{}).
+		Select("activities.category_id, COUNT(DISTINCT activities.id) AS count").
+		Where("activities.user_id = ? AND activities.pending = false AND activities.start_time >= ?", userID, time.Now().AddDate(-1, 0, 0))
+	if tokens != nil {
+		query = query.Joins("JOIN activity_search_tokens ON activity_search_tokens.activity_id = activities.id").
+			Where("activity_search_tokens.token IN ?", tokens)
+	}
+	var counts []categoryCount
+	err := query.Group("activities.category_id").Scan(&counts).Error
+	return counts, err
+}
+
+// guessCategories ranks the categories for a quick entry description, best first. A
+// category scores for its name being in the description, for matching a hint of a word
+// of the description, and for the share of the user's activities with these words
+// filed under it. Without any of these the user's most used category is the guess.
+func (h *Handler) guessCategories(userID uint, description string) ([]categoryGuess, error) {
+	var categories []models.Category
+	if err := h.db.Order("id ASC").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	words := map[string]bool{}
+	hints := map[string]bool{}
+	for _, word := range lowerWords(description) {
+		words[stem(word)] = true
+		for _, key := range []string{word, stem(word)} {
+			for _, hint := range categoryHints[key] {
+				hints[stem(hint)] = true
+			}
+		}
+	}
+
+	guesses := make([]categoryGuess, len(categories))
+	index := map[uint]int{}
+	for i, category := range categories {
+		guesses[i].category = category
+		index[category.ID] = i
+		var named, hinted bool
+		for _, word := range lowerWords(category.Name) {
+			named = named || words[stem(word)]
+			hinted = hinted || hints[stem(word)]
+		}
+		if named {
+			guesses[i].score += 3
+		}
+		if hinted {
+			guesses[i].score += 2
+		}
+	}
+
+	if tokens := h.blindIndexService.Tokens(description); len(tokens) > 0 {
+		counts, err := h.categoryCounts(userID, tokens)
+		if err != nil {
+			return nil, err
+		}
+		var total int64
+		for _, count := range counts {
+			total += count.Count
+		}
+		for _, count := range counts {
+			if i, ok := index[count.CategoryID]; ok {
+				guesses[i].score += 4 * float64(count.Count) / float64(total)
+			}
+		}
+	}
+
+	var sum float64
+	for _, guess := range guesses {
+		sum += guess.score
+	}
+	if sum == 0 {
+		counts, err := h.categoryCounts(userID, nil)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(counts, func(i, j int) bool { return counts[i].Count > counts[j].Count })
+		if len(counts) > 0 {
+			if i, ok := index[counts[0].CategoryID]; ok {
+				guesses[i].score, sum = 0.5, 0.5
+			}
+		}
+	}
+
+	for i := range guesses {
+		guesses[i].confidence = guesses[i].score / (sum + 1)
+	}
+	sort.SliceStable(guesses, func(i, j int) bool { return guesses[i].score > guesses[j].score })
+	return guesses, nil
+}
+
+// usualTimes returns the usual start time of day and duration of the user's recent
+// activities in a category, or no defaults when there are too few of them
+func (h *Handler) usualTimes(userID, categoryID uint, loc *time.Location) (quickentry.Defaults, error) {
+	var activities []models.Activity
+	err := h.db.Select("start_time", "end_time").
+		Where("user_id = ? AND category_id = ? AND end_time IS NOT NULL AND pending = ?", userID, categoryID, false).
+		Order("start_time DESC").
+		Limit(20).
+		Find(&activities).Error
+	if err != nil || len(activities) < 3 {
+		return quickentry.Defaults{}, err
+	}
+
+	starts := make([]int, len(activities))
+	durations := make([]time.Duration, len(activities))
+	for i, activity := range activities {
+		start := activity.StartTime.In(loc)
+		starts[i] = start.Hour()*60 + start.Minute()
+		durations[i] = activity.EndTime.Sub(activity.StartTime).Round(time.Minute)
+	}
+	sort.Ints(starts)
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+
+	// The middle values, so that one long or odd activity does not shift them
+	start := starts[len(starts)/2]
+	return quickentry.Defaults{Start: &start, Duration: durations[len(durations)/2]}, nil
+}
+
+// parsedActivity combines a time reading and a category guess into a proposal
+func parsedActivity(description string, candidate quickentry.Candidate, guess categoryGuess) types.ParsedActivity {
+	assumed := append([]string{}, candidate.Assumed...)
+	if description == "" {
+		description = guess.category.Name
+		assumed = append(assumed, "description")
+	}
+	return types.ParsedActivity{
+		Description:  description,
+		StartTime:    candidate.Start,
+		EndTime:      candidate.End,
+		CategoryID:   guess.category.ID,
+		CategoryName: guess.category.Name,
+		Confidence:   math.Round(candidate.Confidence*guess.confidence*100) / 100,
+		Assumed:      assumed,
+	}
+}
+
+// ParseActivity turns quick entry text such as "gym 7-8:30am" into a proposed activity
+// in the user's timezone, with the category guessed from its words and the user's
+// history. Nothing is saved unless commit=true, which creates the proposal the same way
+// CreateActivity does.
+func (h *Handler) ParseActivity(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	loc := user.Location()
+
+	var input types.ParseRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+	input.Text = strings.TrimSpace(input.Text)
+	if input.Text == "" {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			"text cannot be empty",
+		))
+		return
+	}
+
+	var query types.ParseQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid query parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	entry := quickentry.Parse(input.Text)
+
+	guesses, err := h.guessCategories(user.ID, entry.Description)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to guess category",
+			err.Error(),
+		))
+		return
+	}
+	if len(guesses) == 0 {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"No category available",
+			"There are no categories to file the activity under",
+		))
+		return
+	}
+
+	defaults, err := h.usualTimes(user.ID, guesses[0].category.ID, loc)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch usual times",
+			err.Error(),
+		))
+		return
+	}
+	candidates := entry.Resolve(time.Now(), loc, defaults)
+
+	result := types.ParseResult{
+		Text:         input.Text,
+		Proposal:     parsedActivity(entry.Description, candidates[0], guesses[0]),
+		Alternatives: []types.ParsedActivity{},
+	}
+	for i := 1; i < len(candidates) && i <= maxParseAlternatives; i++ {
+		result.Alternatives = append(result.Alternatives, parsedActivity(entry.Description, candidates[i], guesses[0]))
+	}
+	for i := 1; i < len(guesses) && i <= maxParseAlternatives && guesses[i].score > 0; i++ {
+		result.Alternatives = append(result.Alternatives, parsedActivity(entry.Description, candidates[0], guesses[i]))
+	}
+	sort.SliceStable(result.Alternatives, func(i, j int) bool {
+		return result.Alternatives[i].Confidence > result.Alternatives[j].Confidence
+	})
+
+	if !query.Commit {
+		c.JSON(http.StatusOK, types.NewSuccessResponse(
+			"Quick entry parsed successfully",
+			result,
+			nil,
+		))
+		return
+	}
+
+	proposal := result.Proposal
+	activity := models.Activity{
+		StartTime:  proposal.StartTime,
+		EndTime:    &proposal.EndTime,
+		CategoryID: proposal.CategoryID,
+		UserID:     user.ID,
+	}
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockUser(tx, activity.UserID); err != nil {
+			return err
+		}
+		return h.insertActivity(tx, &activity, proposal.Description, "", nil, query.Overlap)
+	})
+	if err != nil {
+		respondSaveError(c, err, "Failed to create activity")
+		return
+	}
+
+	result.ActivityID = &activity.ID
+	c.JSON(http.StatusCreated, types.NewSuccessResponse(
+		"Activity created successfully",
+		result,
+		nil,
+	))
+}
@@ -0,0 +1,533 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/rrule"
+	"dailyact/types"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// recurringSource is the external source of activities generated from recurring templates
+const recurringSource = "recurring"
+
+// maxRecurringBackfill limits how far back occurrences are generated, so a template
+// starting long ago does not flood the user with activities to confirm
+const maxRecurringBackfill = 31 * 24 * time.Hour
+
+// recurringTemplateFields validates a template request, normalizing its times, and
+// returns the canonical rule and the first day of the recurrence. loc is the owner's
+// time zone, in which an UNTIL without one is read.
+func recurringTemplateFields(input *models.RecurringTemplateRequest, loc *time.Location) (string, time.Time, error) {
+	startDate, err := types.ParseDay(input.StartDate, time.UTC)
+	if err != nil {
+		return "", time.Time{}, errors.New("start_date must be formatted as YYYY-MM-DD")
+	}
+	startTime, err := time.Parse("15:04", input.StartTime)
+	if err != nil {
+		return "", time.Time{}, errors.New("start_time must be formatted as HH:MM")
+	}
+	endTime, err := time.Parse("15:04", input.EndTime)
+	if err != nil {
+		return "", time.Time{}, errors.New("end_time must be formatted as HH:MM")
+	}
+	if startTime.Equal(endTime) {
+		return "", time.Time{}, errors.New("end_time must differ from start_time")
+	}
+	input.StartTime, input.EndTime = startTime.Format("15:04"), endTime.Format("15:04")
+
+	rule, err := rrule.Parse(input.RRule, loc)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid rrule: %w", err)
+	}
+	return rule.String(), startDate, nil
+}
+
+// generateOccurrences turns the template's occurrences that have ended by now into
+// pending activities. Occurrences overlapping an existing activity are skipped. The
+// caller must hold the user lock (see lockUser).
+func (h *Handler) generateOccurrences(tx *gorm.DB, template *models.RecurringTemplate, loc *time.Location, now time.Time) (ids []uint, skipped int, err error) {
+	firstStart, _ := template.Occurrence(template.StartDate, loc)
+	rule, err := rrule.Parse(template.RRule, loc)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	from := now.Add(-maxRecurringBackfill)
+	if template.GeneratedUntil != nil && template.GeneratedUntil.After(from) {
+		from = *template.GeneratedUntil
+	}
+	occurrences := rule.Between(firstStart, from, now)
+
+	externalIDs := make([]string, len(occurrences))
+	for i, occurrence := range occurrences {
+		externalIDs[i] = fmt.Sprintf("%d/%s", template.ID, occurrence.UTC().Format("20060102T150405Z"))
+	}
+	existing, err := existingExternalIDs(tx, template.UserID, recurringSource, externalIDs)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	generatedUntil := now
+	for i, occurrence := range occurrences {
+		start, end := template.Occurrence(occurrence, loc)
+		// Occurrences still under way are generated once they end
+		if end.After(now) {
+			generatedUntil = start
+			break
+		}
+		if existing[externalIDs[i]] {
+			continue
+		}
+
+		externalID := externalIDs[i]
+		source := recurringSource
+		activity := models.Activity{
+			StartTime:      start,
+			EndTime:        &end,
+			CategoryID:     template.CategoryID,
+			UserID:         template.UserID,
+			Pending:        true,
+			ExternalSource: &source,
+			ExternalID:     &externalID,
+		}
+		err := h.insertActivity(tx, &activity, template.Description.String(), template.Notes.String(), nil, "reject")
+		var overlap *overlapError
+		if errors.As(err, &overlap) {
+			skipped++
+			continue
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		ids = append(ids, activity.ID)
+	}
+
+	if err := tx.Model(template).UpdateColumn("generated_until", generatedUntil).Error; err != nil {
+		return nil, 0, err
+	}
+	template.GeneratedUntil = &generatedUntil
+	return ids, skipped, nil
+}
+
+// generateUserOccurrences runs generateOccurrences for every active template of the user
+// in one transaction
+func (h *Handler) generateUserOccurrences(userID uint, now time.Time) (types.RecurringGenerateResult, error) {
+	result := types.RecurringGenerateResult{ActivityIDs: []uint{}}
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockUser(tx, userID); err != nil {
+			return err
+		}
+		var user models.User
+		if err := tx.Select("id", "timezone").First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		var templates []models.RecurringTemplate
+		if err := tx.Where("user_id = ? AND paused = ?", userID, false).Find(&templates).Error; err != nil {
+			return err
+		}
+		for i := range templates {
+			ids, skipped, err := h.generateOccurrences(tx, &templates[i], user.Location(), now)
+			if err != nil {
+				return fmt.Errorf("template %d: %w", templates[i].ID, err)
+			}
+			result.ActivityIDs = append(result.ActivityIDs, ids...)
+			result.Skipped += skipped
+		}
+		return nil
+	})
+	result.Created = len(result.ActivityIDs)
+	return result, err
+}
+
+// StartRecurringGeneration turns due occurrences of every user's recurring templates into
+// pending activities now and then at every interval, until the process exits
+func (h *Handler) StartRecurringGeneration(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			var userIDs []uint
+			if err := h.db.Model(&models.RecurringTemplate{}).Where("paused = ?", false).Distinct("user_id").Pluck("user_id", &userIDs).Error; err != nil {
+				log.Println("Failed to list recurring templates:", err)
+			}
+			created := 0
+			for _, userID := range userIDs {
+				result, err := h.generateUserOccurrences(userID, time.Now())
+				if err != nil {
+					log.Printf("Failed to generate recurring activities of user %d: %v", userID, err)
+					continue
+				}
+				created += result.Created
+			}
+			if created > 0 {
+				log.Printf("Generated %d activities from recurring templates", created)
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+func (h *Handler) CreateRecurringTemplate(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var input models.RecurringTemplateRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+	rule, startDate, err := recurringTemplateFields(&input, user.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.First(&models.Category{}, input.CategoryID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Category not found",
+			err.Error(),
+		))
+		return
+	}
+
+	descriptionEncrypted, err := h.encryptionService.Encrypt(input.Description)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"ENCRYPTION_ERROR",
+			"Failed to encrypt description",
+			err.Error(),
+		))
+		return
+	}
+	notesEncrypted, err := h.encryptionService.Encrypt(input.Notes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"ENCRYPTION_ERROR",
+			"Failed to encrypt notes",
+			err.Error(),
+		))
+		return
+	}
+
+	template := models.RecurringTemplate{
+		UserID:      user.ID,
+		CategoryID:  input.CategoryID,
+		Description: models.EncryptedString(descriptionEncrypted),
+		Notes:       models.EncryptedString(notesEncrypted),
+		RRule:       rule,
+		StartDate:   startDate,
+		StartTime:   input.StartTime,
+		EndTime:     input.EndTime,
+		Paused:      input.Paused,
+	}
+	if err := h.db.Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to create recurring template",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.Preload("Category").First(&template, template.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to reload recurring template data",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusCreated, types.NewSuccessResponse(
+		"Recurring template created successfully",
+		template,
+		nil,
+	))
+}
+
+func (h *Handler) GetRecurringTemplates(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var query types.PaginationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid pagination parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	db := h.db.Model(&models.RecurringTemplate{}).Where("user_id = ?", user.ID)
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to count recurring templates",
+			err.Error(),
+		))
+		return
+	}
+
+	var templates []models.RecurringTemplate
+	offset := (query.Page - 1) * query.PageSize
+	if err := db.Preload("Category").Order("created_at DESC").Offset(offset).Limit(query.PageSize).Find(&templates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch recurring templates",
+			err.Error(),
+		))
+		return
+	}
+
+	pagination := types.NewPaginationResponse(query.Page, query.PageSize, total)
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Recurring templates retrieved successfully",
+		templates,
+		&pagination,
+	))
+}
+
+func (h *Handler) GetRecurringTemplateByID(c *gin.Context) {
+	var template models.RecurringTemplate
+	if err := h.db.Preload("Category").First(&template, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Recurring template not found",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Recurring template retrieved successfully",
+		template,
+		nil,
+	))
+}
+
+// UpdateRecurringTemplate replaces a template. Changes apply to the occurrences not
+// generated yet, activities generated before are left as they are.
+func (h *Handler) UpdateRecurringTemplate(c *gin.Context) {
+	var template models.RecurringTemplate
+	if err := h.db.First(&template, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Recurring template not found",
+			err.Error(),
+		))
+		return
+	}
+
+	var input models.RecurringTemplateRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+	var owner models.User
+	if err := h.db.Select("id", "timezone").First(&owner, template.UserID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch template owner",
+			err.Error(),
+		))
+		return
+	}
+	rule, startDate, err := recurringTemplateFields(&input, owner.Location())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.First(&models.Category{}, input.CategoryID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Category not found",
+			err.Error(),
+		))
+		return
+	}
+
+	descriptionEncrypted, err := h.encryptionService.Encrypt(input.Description)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"ENCRYPTION_ERROR",
+			"Failed to encrypt description",
+			err.Error(),
+		))
+		return
+	}
+	notesEncrypted, err := h.encryptionService.Encrypt(input.Notes)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"ENCRYPTION_ERROR",
+			"Failed to encrypt notes",
+			err.Error(),
+		))
+		return
+	}
+
+	template.CategoryID = input.CategoryID
+	template.Description = models.EncryptedString(descriptionEncrypted)
+	template.Notes = models.EncryptedString(notesEncrypted)
+	template.RRule = rule
+	template.StartDate = startDate
+	template.StartTime = input.StartTime
+	template.EndTime = input.EndTime
+	template.Paused = input.Paused
+
+	if err := h.db.Save(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to update recurring template",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.Preload("Category").First(&template, template.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to reload recurring template data",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Recurring template updated successfully",
+		template,
+		nil,
+	))
+}
+
+// DeleteRecurringTemplate deletes a template. Activities generated from it are kept.
+func (h *Handler) DeleteRecurringTemplate(c *gin.Context) {
+	var template models.RecurringTemplate
+	if err := h.db.First(&template, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Recurring template not found",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.Delete(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to delete recurring template",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Recurring template deleted successfully",
+		nil,
+		nil,
+	))
+}
+
+// GenerateRecurringActivities turns the due occurrences of the current user's templates
+// into pending activities right away, instead of waiting for the background job
+func (h *Handler) GenerateRecurringActivities(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	result, err := h.generateUserOccurrences(user.ID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to generate recurring activities",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Recurring activities generated successfully",
+		result,
+		nil,
+	))
+}
+
+// ConfirmActivity marks a pending activity generated from a recurring template as confirmed
+func (h *Handler) ConfirmActivity(c *gin.Context) {
+	var overlapQuery types.OverlapQuery
+	if err := c.ShouldBindQuery(&overlapQuery); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid overlap policy",
+			err.Error(),
+		))
+		return
+	}
+
+	var activity models.Activity
+	if err := h.db.First(&activity, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Activity not found",
+			err.Error(),
+		))
+		return
+	}
+
+	if activity.Pending {
+		// Activities logged since the occurrence was generated may take its slot
+		err := h.db.Transaction(func(tx *gorm.DB) error {
+			if err := lockUser(tx, activity.UserID); err != nil {
+				return err
+			}
+			if err := h.resolveOverlaps(tx, activity.UserID, activity.ID, activity.StartTime, activity.EndTime, overlapQuery.Overlap); err != nil {
+				return err
+			}
+			// Only touch the pending column, the loaded description and notes are decrypted
+			return tx.Model(&activity).UpdateColumn("pending", false).Error
+		})
+		if err != nil {
+			respondSaveError(c, err, "Failed to confirm activity")
+			return
+		}
+	}
+
+	if err := h.db.Preload("Category").Preload("Tags").First(&activity, activity.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to reload activity data",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Activity confirmed successfully",
+		activity,
+		nil,
+	))
+}
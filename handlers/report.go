@@ -0,0 +1,192 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/types"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// reportRow is one aggregated row of a report query
+type reportRow struct {
+	PeriodStart  time.Time
+	CategoryID   uint
+	CategoryName string
+	TotalSeconds int64
+}
+
+// tagReportRow is one aggregated row of a report broken out by tag
+type tagReportRow struct {
+	PeriodStart  time.Time
+	TagID        *uint
+	TagName      string
+	TotalSeconds int64
+}
+
+// GetDailyReport returns the time spent per category for each day
+func (h *Handler) GetDailyReport(c *gin.Context) {
+	h.getReport(c, "day")
+}
+
+// GetWeeklyReport returns the time spent per category for each week, starting on Monday
+func (h *Handler) GetWeeklyReport(c *gin.Context) {
+	h.getReport(c, "week")
+}
+
+func (h *Handler) getReport(c *gin.Context, period string) {
+	user := c.MustGet("user").(models.User)
+
+	var query struct {
+		types.ActivityFilter
+		Breakdown string `form:"breakdown,default=category" binding:"oneof=category tag"`
+	}
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid filter parameters",
+			err.Error(),
+		))
+		return
+	}
+	filter := query.ActivityFilter
+
+	loc := user.Location()
+	rangeStart, rangeEnd, err := filterDateRange(filter, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid filter parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	// Dates select days rather than start times, so an activity crossing
+	// midnight into the range still counts for the part inside it
+	activityFilter := filter
+	activityFilter.StartDate, activityFilter.EndDate = nil, nil
+
+	activities := h.db.Model(&models.Activity{}).Where("activities.user_id = ?", user.ID)
+	activities, err = h.applyActivityFilter(activities, activityFilter, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid filter parameters",
+			err.Error(),
+		))
+		return
+	}
+	if rangeStart != nil {
+		activities = activities.Where("COALESCE(activities.end_time, NOW()) > ?", *rangeStart)
+	}
+	if rangeEnd != nil {
+		activities = activities.Where("activities.start_time < ?", *rangeEnd)
+	}
+
+	// Activities crossing midnight are split so each local day gets its own share
+	slices := func() *gorm.DB {
+		db := h.db.Table("(?) AS s", daySlicesQuery(h.db, activities, loc.String())).Where("s.seconds > 0")
+		if filter.StartDate != nil {
+			db = db.Where("s.local_day >= ?::date", *filter.StartDate)
+		}
+		if filter.EndDate != nil {
+			db = db.Where("s.local_day <= ?::date", *filter.EndDate)
+		}
+		return db
+	}
+
+	var rows []reportRow
+	if err := slices().
+		Joins("JOIN categories ON categories.id = s.category_id").
+		Select(
+			"date_trunc(?, s.local_day) AS period_start, s.category_id, "+
+				"categories.name AS category_name, SUM(s.seconds)::bigint AS total_seconds",
+			period,
+		).
+		Group("period_start, s.category_id, categories.name").
+		Order("period_start, categories.name").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to build report",
+			err.Error(),
+		))
+		return
+	}
+
+	periods := groupReportRows(rows)
+
+	if query.Breakdown == "tag" {
+		// An activity with several tags counts for each of them, untagged time has no tag_id
+		var tagRows []tagReportRow
+		if err := slices().
+			Joins("LEFT JOIN activity_tags ON activity_tags.activity_id = s.activity_id").
+			Joins("LEFT JOIN tags ON tags.id = activity_tags.tag_id").
+			Select(
+				"date_trunc(?, s.local_day) AS period_start, tags.id AS tag_id, "+
+					"COALESCE(tags.name, '') AS tag_name, SUM(s.seconds)::bigint AS total_seconds",
+				period,
+			).
+			Group("period_start, tags.id, tags.name").
+			Order("period_start, tags.name").
+			Scan(&tagRows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+				"DB_ERROR",
+				"Failed to build report",
+				err.Error(),
+			))
+			return
+		}
+		addTagTotals(periods, tagRows)
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Report retrieved successfully",
+		periods,
+		nil,
+	))
+}
+
+// groupReportRows folds rows ordered by period into one entry per period
+func groupReportRows(rows []reportRow) []types.ReportPeriod {
+	periods := []types.ReportPeriod{}
+	for _, row := range rows {
+		key := row.PeriodStart.Format("2006-01-02")
+		if len(periods) == 0 || periods[len(periods)-1].PeriodStart != key {
+			periods = append(periods, types.ReportPeriod{PeriodStart: key, Categories: []types.CategoryTotal{}})
+		}
+
+		current := &periods[len(periods)-1]
+		current.TotalSeconds += row.TotalSeconds
+		current.Categories = append(current.Categories, types.CategoryTotal{
+			CategoryID:   row.CategoryID,
+			CategoryName: row.CategoryName,
+			TotalSeconds: row.TotalSeconds,
+		})
+	}
+	return periods
+}
+
+// addTagTotals attaches the per tag totals to their periods
+func addTagTotals(periods []types.ReportPeriod, rows []tagReportRow) {
+	index := map[string]int{}
+	for i := range periods {
+		index[periods[i].PeriodStart] = i
+		periods[i].Tags = []types.TagTotal{}
+	}
+
+	for _, row := range rows {
+		i, ok := index[row.PeriodStart.Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		periods[i].Tags = append(periods[i].Tags, types.TagTotal{
+			TagID:        row.TagID,
+			TagName:      row.TagName,
+			TotalSeconds: row.TotalSeconds,
+		})
+	}
+}
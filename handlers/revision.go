@@ -0,0 +1,337 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/types"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// loadActivityState returns the current values of an activity as an unsaved revision
+// with plaintext description and notes
+func loadActivityState(tx *gorm.DB, activityID uint) (models.ActivityRevision, error) {
+	var activity models.Activity
+	if err := tx.Preload("Tags").First(&activity, activityID).Error; err != nil {
+		return models.ActivityRevision{}, err
+	}
+
+	tags := make([]string, len(activity.Tags))
+	for i, tag := range activity.Tags {
+		tags[i] = tag.Name
+	}
+	sort.Strings(tags)
+
+	return models.ActivityRevision{
+		ActivityID:  activity.ID,
+		StartTime:   activity.StartTime,
+		EndTime:     activity.EndTime,
+		Description: activity.Description,
+		Notes:       activity.Notes,
+		Mood:        activity.Mood,
+		Energy:      activity.Energy,
+		CategoryID:  activity.CategoryID,
+		Tags:        tags,
+	}, nil
+}
+
+// changedActivityFields lists the fields that differ between two states of an activity
+func changedActivityFields(before, after models.ActivityRevision) []string {
+	var fields []string
+	if !before.StartTime.Equal(after.StartTime) {
+		fields = append(fields, "start_time")
+	}
+	if (before.EndTime == nil) != (after.EndTime == nil) ||
+		(before.EndTime != nil && !before.EndTime.Equal(*after.EndTime)) {
+		fields = append(fields, "end_time")
+	}
+	if before.Description.String() != after.Description.String() {
+		fields = append(fields, "description")
+	}
+	if before.Notes.String() != after.Notes.String() {
+		fields = append(fields, "notes")
+	}
+	if !equalRating(before.Mood, after.Mood) {
+		fields = append(fields, "mood")
+	}
+	if !equalRating(before.Energy, after.Energy) {
+		fields = append(fields, "energy")
+	}
+	if before.CategoryID != after.CategoryID {
+		fields = append(fields, "category_id")
+	}
+	if !equalTagNames(before.Tags, after.Tags) {
+		fields = append(fields, "tags")
+	}
+	return fields
+}
+
+// equalRating reports whether two optional ratings are the same
+func equalRating(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// equalTagNames reports whether two sorted lists of tag names are the same
+func equalTagNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// recordRevision compares the activity with the state loaded before a change and stores
+// that state as a new revision when anything differs. It must run in the transaction
+// making the change, after the change, while holding the user lock (see lockUser).
+func (h *Handler) recordRevision(tx *gorm.DB, before models.ActivityRevision, changedByID uint) error {
+	after, err := loadActivityState(tx, before.ActivityID)
+	if err != nil {
+		return err
+	}
+
+	fields := changedActivityFields(before, after)
+	if len(fields) == 0 {
+		return nil
+	}
+
+	// The loaded state holds decrypted values, encrypt them for storage
+	descriptionEncrypted, err := h.encryptionService.Encrypt(before.Description.String())
+	if err != nil {
+		return err
+	}
+	notesEncrypted, err := h.encryptionService.Encrypt(before.Notes.String())
+	if err != nil {
+		return err
+	}
+
+	var last int
+	if err := tx.Model(&models.ActivityRevision{}).
+		Select("COALESCE(MAX(revision), 0)").
+		Where("activity_id = ?", before.ActivityID).
+		Scan(&last).Error; err != nil {
+		return err
+	}
+
+	revision := before
+	revision.Revision = last + 1
+	revision.ChangedByID = changedByID
+	revision.ChangedFields = fields
+	revision.Description = models.EncryptedString(descriptionEncrypted)
+	revision.Notes = models.EncryptedString(notesEncrypted)
+	return tx.Create(&revision).Error
+}
+
+// GetActivityHistory lists the revisions of an activity, newest first
+func (h *Handler) GetActivityHistory(c *gin.Context) {
+	var activity models.Activity
+	if err := h.db.First(&activity, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Activity not found",
+			err.Error(),
+		))
+		return
+	}
+
+	var query types.PaginationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid pagination parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	db := h.db.Model(&models.ActivityRevision{}).Where("activity_revisions.activity_id = ?", activity.ID)
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to count revisions",
+			err.Error(),
+		))
+		return
+	}
+
+	var revisions []models.ActivityRevision
+	offset := (query.Page - 1) * query.PageSize
+	err := db.Select("activity_revisions.*, users.name AS changed_by_name").
+		Joins("LEFT JOIN users ON users.id = activity_revisions.changed_by_id").
+		Order("activity_revisions.revision DESC").
+		Offset(offset).
+		Limit(query.PageSize).
+		Find(&revisions).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch revisions",
+			err.Error(),
+		))
+		return
+	}
+
+	pagination := types.NewPaginationResponse(query.Page, query.PageSize, total)
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Activity history retrieved successfully",
+		revisions,
+		&pagination,
+	))
+}
+
+// RevertActivity brings back the values an activity had before the given revision.
+// The revert is itself recorded as a revision, so it can be undone.
+func (h *Handler) RevertActivity(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var activity models.Activity
+	if err := h.db.First(&activity, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Activity not found",
+			err.Error(),
+		))
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("rev"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid revision number",
+			err.Error(),
+		))
+		return
+	}
+
+	var revision models.ActivityRevision
+	if err := h.db.Where("activity_id = ? AND revision = ?", activity.ID, number).First(&revision).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Revision not found",
+			err.Error(),
+		))
+		return
+	}
+
+	if err := h.db.First(&models.Category{}, revision.CategoryID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Category of the revision no longer exists",
+			err.Error(),
+		))
+		return
+	}
+
+	var overlapQuery types.OverlapQuery
+	if err := c.ShouldBindQuery(&overlapQuery); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid overlap policy",
+			err.Error(),
+		))
+		return
+	}
+
+	// The revision was decrypted on load, encrypt its values again for the activity
+	descriptionEncrypted, err := h.encryptionService.Encrypt(revision.Description.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"ENCRYPTION_ERROR",
+			"Failed to encrypt description",
+			err.Error(),
+		))
+		return
+	}
+	notesEncrypted, err := h.encryptionService.Encrypt(revision.Notes.String())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"ENCRYPTION_ERROR",
+			"Failed to encrypt notes",
+			err.Error(),
+		))
+		return
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockUser(tx, activity.UserID); err != nil {
+			return err
+		}
+
+		before, err := loadActivityState(tx, activity.ID)
+		if err != nil {
+			return err
+		}
+
+		// Reverting to a time the timer was running starts it again
+		if revision.EndTime == nil && before.EndTime != nil {
+			running, err := findRunningActivity(tx, activity.UserID)
+			if err != nil {
+				return err
+			}
+			if running != nil {
+				return models.ErrActivityRunning
+			}
+		}
+
+		if err := h.resolveOverlaps(tx, activity.UserID, activity.ID, revision.StartTime, revision.EndTime, overlapQuery.Overlap); err != nil {
+			return err
+		}
+
+		activity.StartTime = revision.StartTime
+		activity.EndTime = revision.EndTime
+		activity.Description = models.EncryptedString(descriptionEncrypted)
+		activity.Notes = models.EncryptedString(notesEncrypted)
+		activity.Mood = revision.Mood
+		activity.Energy = revision.Energy
+		activity.CategoryID = revision.CategoryID
+		if err := tx.Model(&activity).
+			Select("date", "start_time", "end_time", "duration", "description", "notes", "mood", "energy", "category_id", "updated_at").
+			Updates(&activity).Error; err != nil {
+			return err
+		}
+		if err := h.indexActivity(tx, activity.ID, revision.Description.String(), revision.Notes.String()); err != nil {
+			return err
+		}
+
+		tags, err := findOrCreateTags(tx, activity.UserID, revision.Tags)
+		if err != nil {
+			return err
+		}
+		if err := setActivityTags(tx, activity.ID, tags); err != nil {
+			return err
+		}
+
+		return h.recordRevision(tx, before, user.ID)
+	})
+	if err != nil {
+		respondSaveError(c, err, "Failed to revert activity")
+		return
+	}
+
+	if err := h.db.Preload("Category").Preload("Tags").First(&activity, activity.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to reload activity data",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Activity reverted successfully",
+		activity,
+		nil,
+	))
+}
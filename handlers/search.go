@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// indexActivity replaces the search tokens of an activity with the tokens of its
+// plaintext description and notes. Only the tokens are stored.
+func (h *Handler) indexActivity(tx *gorm.DB, activityID uint, description, notes string) error {
+	if err := tx.Where("activity_id = ?", activityID).Delete(&models.ActivitySearchToken{}).Error; err != nil {
+		return err
+	}
+
+	tokens := h.blindIndexService.Tokens(description, notes)
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	rows := make([]models.ActivitySearchToken, len(tokens))
+	for i, token := range tokens {
+		rows[i] = models.ActivitySearchToken{ActivityID: activityID, Token: token}
+	}
+	return tx.Create(&rows).Error
+}
+
+// searchActivities narrows an activities query to activities containing every word of q.
+// A q with no searchable words, such as one of single letters, matches nothing.
+func (h *Handler) searchActivities(db *gorm.DB, q string) *gorm.DB {
+	if strings.TrimSpace(q) == "" {
+		return db
+	}
+	tokens := h.blindIndexService.Tokens(q)
+	if len(tokens) == 0 {
+		return db.Where("1 = 0")
+	}
+
+	return db.Where(
+		"activities.id IN (SELECT activity_id FROM activity_search_tokens WHERE token IN ? GROUP BY activity_id HAVING COUNT(*) = ?)",
+		tokens, len(tokens),
+	)
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// activitySortColumns maps the sort keys of activity listings to SQL expressions
+var activitySortColumns = map[string]string{
+	"created_at": "activities.created_at",
+	"start_time": "activities.start_time",
+	"duration":   liveDurationSQL,
+	"category":   "categories.name",
+}
+
+// applyActivitySort orders an activities query by the sort key, with the id breaking ties
+// in the same direction so pages stay stable
+func applyActivitySort(db *gorm.DB, key, order string) *gorm.DB {
+	if key == "category" {
+		db = db.Select("activities.*").Joins("JOIN categories ON categories.id = activities.category_id")
+	}
+	direction := strings.ToUpper(order)
+	return db.Order(activitySortColumns[key] + " " + direction + ", activities.id " + direction)
+}
+
+// activitySortValue returns the value of the sort key for an activity loaded with its category
+func activitySortValue(activity models.Activity, key string) interface{} {
+	switch key {
+	case "start_time":
+		return activity.StartTime
+	case "duration":
+		return activity.Duration
+	case "category":
+		return activity.Category.Name
+	default:
+		return activity.CreatedAt
+	}
+}
+
+// decodeActivitySortValue reads a sort value stored in a cursor back into its Go type
+func decodeActivitySortValue(raw json.RawMessage, key string) (interface{}, error) {
+	switch key {
+	case "start_time", "created_at":
+		var value time.Time
+		err := json.Unmarshal(raw, &value)
+		return value, err
+	case "duration":
+		var value int
+		err := json.Unmarshal(raw, &value)
+		return value, err
+	default:
+		var value string
+		err := json.Unmarshal(raw, &value)
+		return value, err
+	}
+}
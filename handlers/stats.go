@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/types"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dailyTotalRow is the time spent on a category during one local day
+type dailyTotalRow struct {
+	CategoryID   uint
+	CategoryName string
+	LocalDay     time.Time
+	TotalSeconds int64
+}
+
+// GetStreaks returns, per category, the streaks of days and weeks with time logged
+// and the streaks of periods in which the category's goals were met
+func (h *Handler) GetStreaks(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	loc := user.Location()
+
+	activities := h.db.Model(&models.Activity{}).Where("activities.user_id = ? AND activities.pending = false", user.ID)
+
+	var rows []dailyTotalRow
+	if err := h.db.Table("(?) AS s", daySlicesQuery(h.db, activities, loc.String())).
+		Joins("JOIN categories ON categories.id = s.category_id").
+		Select("s.category_id, categories.name AS category_name, s.local_day, SUM(s.seconds)::bigint AS total_seconds").
+		Where("s.seconds > 0").
+		Group("s.category_id, categories.name, s.local_day").
+		Order("categories.name, s.local_day").
+		Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to compute streaks",
+			err.Error(),
+		))
+		return
+	}
+
+	var goals []models.Goal
+	if err := h.db.Preload("Category").Where("user_id = ?", user.ID).Order("created_at").Find(&goals).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch goals",
+			err.Error(),
+		))
+		return
+	}
+
+	today := models.LocalDate(time.Now(), loc)
+
+	// Daily totals per category, keyed by the local day at midnight UTC
+	streaks := []types.CategoryStreaks{}
+	totals := map[uint]map[time.Time]int64{}
+	for _, row := range rows {
+		if totals[row.CategoryID] == nil {
+			totals[row.CategoryID] = map[time.Time]int64{}
+			streaks = append(streaks, types.CategoryStreaks{
+				CategoryID:   row.CategoryID,
+				CategoryName: row.CategoryName,
+			})
+		}
+		day := time.Date(row.LocalDay.Year(), row.LocalDay.Month(), row.LocalDay.Day(), 0, 0, 0, 0, time.UTC)
+		totals[row.CategoryID][day] += row.TotalSeconds
+	}
+
+	// Categories with goals but without any activity still get an entry
+	for _, goal := range goals {
+		if totals[goal.CategoryID] == nil {
+			totals[goal.CategoryID] = map[time.Time]int64{}
+			streaks = append(streaks, types.CategoryStreaks{
+				CategoryID:   goal.CategoryID,
+				CategoryName: goal.Category.Name,
+			})
+		}
+	}
+
+	for i := range streaks {
+		days := totals[streaks[i].CategoryID]
+		logged := func(total int64) bool { return total > 0 }
+
+		streaks[i].Daily = computeStreak(periodTotals(days, models.GoalDaily), models.GoalDaily, today, nil, logged, true)
+		streaks[i].Weekly = computeStreak(periodTotals(days, models.GoalWeekly), models.GoalWeekly, today, nil, logged, true)
+
+		streaks[i].Goals = []types.GoalStreak{}
+		for _, goal := range goals {
+			if goal.CategoryID != streaks[i].CategoryID {
+				continue
+			}
+
+			// Goals count from the period they were set in. A max goal can still
+			// be broken during the current period, so it gets no grace period.
+			since := models.LocalDate(goal.CreatedAt, loc)
+			streak := computeStreak(periodTotals(days, goal.Period), goal.Period, today, &since, goal.IsMet, goal.Kind == models.GoalMin)
+			streaks[i].Goals = append(streaks[i].Goals, types.GoalStreak{
+				GoalID: goal.ID,
+				Kind:   string(goal.Kind),
+				Period: string(goal.Period),
+				Streak: streak,
+			})
+		}
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Streaks retrieved successfully",
+		streaks,
+		nil,
+	))
+}
+
+// periodStart returns the first day of the period containing day.
+// Days are local calendar days stored as midnight UTC.
+func periodStart(day time.Time, period models.GoalPeriod) time.Time {
+	switch period {
+	case models.GoalWeekly:
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case models.GoalMonthly:
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return day
+	}
+}
+
+// nextPeriod returns the start of the period following the one starting at start
+func nextPeriod(start time.Time, period models.GoalPeriod) time.Time {
+	switch period {
+	case models.GoalWeekly:
+		return start.AddDate(0, 0, 7)
+	case models.GoalMonthly:
+		return start.AddDate(0, 1, 0)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// periodTotals sums daily totals per period
+func periodTotals(days map[time.Time]int64, period models.GoalPeriod) map[time.Time]int64 {
+	totals := map[time.Time]int64{}
+	for day, seconds := range days {
+		totals[periodStart(day, period)] += seconds
+	}
+	return totals
+}
+
+// computeStreak walks the periods from the first one up to the one containing today
+// and counts consecutive periods whose total satisfies met. Periods without activity
+// have a total of zero. since limits the walk to periods from that day on. With grace,
+// an unmet current period does not break the streak because it is not over yet.
+func computeStreak(totals map[time.Time]int64, period models.GoalPeriod, today time.Time, since *time.Time, met func(int64) bool, grace bool) types.Streak {
+	current := periodStart(today, period)
+
+	var first time.Time
+	if since != nil {
+		first = periodStart(*since, period)
+	} else {
+		if len(totals) == 0 {
+			return types.Streak{}
+		}
+		first = current
+		for start := range totals {
+			if start.Before(first) {
+				first = start
+			}
+		}
+	}
+
+	var streak types.Streak
+	run := 0
+	var runStart time.Time
+	for start := first; !start.After(current); start = nextPeriod(start, period) {
+		if met(totals[start]) {
+			if run == 0 {
+				runStart = start
+			}
+			run++
+			if run > streak.Longest {
+				streak.Longest = run
+			}
+		} else if !start.Equal(current) || !grace {
+			run = 0
+		}
+	}
+
+	if run > 0 {
+		day := runStart.Format("2006-01-02")
+		streak.Current = run
+		streak.CurrentStart = &day
+	}
+	return streak
+}
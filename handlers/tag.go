@@ -0,0 +1,308 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/types"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// normalizeTagNames trims, lowercases and de-duplicates tag names
+func normalizeTagNames(names []string) ([]string, error) {
+	seen := map[string]bool{}
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			return nil, errors.New("tag names cannot be empty")
+		}
+		if len(name) > 64 {
+			return nil, fmt.Errorf("tag name %q is longer than 64 characters", name)
+		}
+		if !seen[name] {
+			seen[name] = true
+			normalized = append(normalized, name)
+		}
+	}
+	return normalized, nil
+}
+
+// findOrCreateTags returns the user's tags with the given normalized names, creating missing ones
+func findOrCreateTags(tx *gorm.DB, userID uint, names []string) ([]models.Tag, error) {
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		var tag models.Tag
+		if err := tx.Where(models.Tag{UserID: userID, Name: name}).FirstOrCreate(&tag).Error; err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, nil
+}
+
+// setActivityTags replaces the tags of an activity
+func setActivityTags(tx *gorm.DB, activityID uint, tags []models.Tag) error {
+	if err := tx.Exec("DELETE FROM activity_tags WHERE activity_id = ?", activityID).Error; err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if err := tx.Exec("INSERT INTO activity_tags (activity_id, tag_id) VALUES (?, ?)", activityID, tag.ID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tag handlers
+func (h *Handler) GetTags(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var tags []models.Tag
+	if err := h.db.Where("user_id = ?", user.ID).Order("name").Find(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch tags",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Tags retrieved successfully",
+		tags,
+		nil,
+	))
+}
+
+func (h *Handler) CreateTag(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var input models.TagRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+
+	names, err := normalizeTagNames([]string{input.Name})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+
+	var existing int64
+	if err := h.db.Model(&models.Tag{}).Where("user_id = ? AND name = ?", user.ID, names[0]).Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to check tag name",
+			err.Error(),
+		))
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, types.NewErrorResponse(
+			"TAG_EXISTS",
+			"Tag already exists",
+			"A tag with this name already exists",
+		))
+		return
+	}
+
+	tag := models.Tag{UserID: user.ID, Name: names[0]}
+	if err := h.db.Create(&tag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to create tag",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusCreated, types.NewSuccessResponse(
+		"Tag created successfully",
+		tag,
+		nil,
+	))
+}
+
+// RenameTag changes the name of a tag. Renaming onto an existing name is refused, merge the tags instead.
+func (h *Handler) RenameTag(c *gin.Context) {
+	var tag models.Tag
+	if err := h.db.First(&tag, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Tag not found",
+			err.Error(),
+		))
+		return
+	}
+
+	var input models.TagRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+
+	names, err := normalizeTagNames([]string{input.Name})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+
+	var existing int64
+	if err := h.db.Model(&models.Tag{}).Where("user_id = ? AND name = ? AND id <> ?", tag.UserID, names[0], tag.ID).Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to check tag name",
+			err.Error(),
+		))
+		return
+	}
+	if existing > 0 {
+		c.JSON(http.StatusConflict, types.NewErrorResponse(
+			"TAG_EXISTS",
+			"Tag already exists",
+			"A tag with this name already exists, merge the tags instead",
+		))
+		return
+	}
+
+	tag.Name = names[0]
+	if err := h.db.Save(&tag).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to rename tag",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Tag renamed successfully",
+		tag,
+		nil,
+	))
+}
+
+// MergeTag moves the activities of a tag over to another tag of the same user and deletes it
+func (h *Handler) MergeTag(c *gin.Context) {
+	var source models.Tag
+	if err := h.db.First(&source, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Tag not found",
+			err.Error(),
+		))
+		return
+	}
+
+	var input models.MergeTagRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+
+	var target models.Tag
+	if err := h.db.Where("user_id = ?", source.UserID).First(&target, input.TargetID).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Target tag not found",
+			err.Error(),
+		))
+		return
+	}
+
+	if target.ID == source.ID {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			"A tag cannot be merged into itself",
+		))
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec(
+			"INSERT INTO activity_tags (activity_id, tag_id) SELECT activity_id, ? FROM activity_tags WHERE tag_id = ? ON CONFLICT DO NOTHING",
+			target.ID, source.ID,
+		).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM activity_tags WHERE tag_id = ?", source.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&source).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to merge tags",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Tags merged successfully",
+		target,
+		nil,
+	))
+}
+
+func (h *Handler) DeleteTag(c *gin.Context) {
+	var tag models.Tag
+	if err := h.db.First(&tag, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Tag not found",
+			err.Error(),
+		))
+		return
+	}
+
+	// Detach the tag from its activities before deleting it
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM activity_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tag).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to delete tag",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Tag deleted successfully",
+		nil,
+		nil,
+	))
+}
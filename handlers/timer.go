@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/types"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// lockUser takes a row lock on the user so that concurrent requests touching
+// the same user's activities are serialized until the transaction ends
+func lockUser(tx *gorm.DB, userID uint) error {
+	var user models.User
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&user, userID).Error
+}
+
+// findRunningActivity returns the user's running activity, or nil if no timer is running
+func findRunningActivity(db *gorm.DB, userID uint) (*models.Activity, error) {
+	var activity models.Activity
+	err := db.Where("user_id = ? AND end_time IS NULL", userID).First(&activity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &activity, nil
+}
+
+// StartActivity starts a timer for a new activity without an end time
+func (h *Handler) StartActivity(c *gin.Context) {
+	user, _ := c.Get("user")
+	userID := user.(models.User).ID
+
+	var input struct {
+		StartTime   *time.Time `json:"start_time"` // defaults to now
+		Description string     `json:"description" binding:"required"`
+		Notes       string     `json:"notes"`
+		Mood        *int       `json:"mood" binding:"omitempty,min=1,max=5"`
+		Energy      *int       `json:"energy" binding:"omitempty,min=1,max=5"`
+		CategoryID  uint       `json:"category_id" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			err.Error(),
+		))
+		return
+	}
+
+	var overlapQuery types.OverlapQuery
+	if err := c.ShouldBindQuery(&overlapQuery); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid overlap policy",
+			err.Error(),
+		))
+		return
+	}
+
+	startTime := time.Now()
+	if input.StartTime != nil {
+		startTime = *input.StartTime
+	}
+	if startTime.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			"start_time cannot be in the future",
+		))
+		return
+	}
+
+	// Encrypt description and notes
+	descriptionEncrypted, err := h.encryptionService.Encrypt(input.Description)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"ENCRYPTION_ERROR",
+			"Failed to encrypt description",
+			err.Error(),
+		))
+		return
+	}
+
+	var notesEncrypted string
+	if input.Notes != "" {
+		notesEncrypted, err = h.encryptionService.Encrypt(input.Notes)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+				"ENCRYPTION_ERROR",
+				"Failed to encrypt notes",
+				err.Error(),
+			))
+			return
+		}
+	}
+
+	activity := models.Activity{
+		StartTime:   startTime,
+		Description: models.EncryptedString(descriptionEncrypted),
+		Notes:       models.EncryptedString(notesEncrypted),
+		Mood:        input.Mood,
+		Energy:      input.Energy,
+		CategoryID:  input.CategoryID,
+		UserID:      userID,
+	}
+
+	err = h.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockUser(tx, userID); err != nil {
+			return err
+		}
+
+		running, err := findRunningActivity(tx, userID)
+		if err != nil {
+			return err
+		}
+		if running != nil {
+			return models.ErrActivityRunning
+		}
+
+		if err := h.resolveOverlaps(tx, userID, 0, activity.StartTime, nil, overlapQuery.Overlap); err != nil {
+			return err
+		}
+		if err := tx.Create(&activity).Error; err != nil {
+			return err
+		}
+		return h.indexActivity(tx, activity.ID, input.Description, input.Notes)
+	})
+	if err != nil {
+		respondSaveError(c, err, "Failed to start activity")
+		return
+	}
+
+	// Reload the activity with Category so the response carries decrypted fields
+	if err := h.db.Preload("Category").Preload("Tags").First(&activity, activity.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to reload activity data",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusCreated, types.NewSuccessResponse(
+		"Activity started successfully",
+		activity,
+		nil,
+	))
+}
+
+// StopActivity stops a running activity and stores its final duration
+func (h *Handler) StopActivity(c *gin.Context) {
+	var input struct {
+		EndTime *time.Time `json:"end_time"` // defaults to now
+	}
+
+	// The body is optional, an empty request stops the timer now
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+				"INVALID_INPUT",
+				"Invalid input data",
+				err.Error(),
+			))
+			return
+		}
+	}
+
+	var overlapQuery types.OverlapQuery
+	if err := c.ShouldBindQuery(&overlapQuery); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid overlap policy",
+			err.Error(),
+		))
+		return
+	}
+
+	var activity models.Activity
+	if err := h.db.First(&activity, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Activity not found",
+			err.Error(),
+		))
+		return
+	}
+
+	if !activity.IsRunning {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"TIMER_NOT_RUNNING",
+			"Activity is not running",
+			"Only running activities can be stopped",
+		))
+		return
+	}
+
+	endTime := time.Now()
+	if input.EndTime != nil {
+		endTime = *input.EndTime
+	}
+	if endTime.Before(activity.StartTime) {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_INPUT",
+			"Invalid input data",
+			"end_time cannot be before start_time",
+		))
+		return
+	}
+
+	// Only touch the timing columns, the loaded description and notes are decrypted
+	activity.EndTime = &endTime
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockUser(tx, activity.UserID); err != nil {
+			return err
+		}
+		before, err := loadActivityState(tx, activity.ID)
+		if err != nil {
+			return err
+		}
+		if err := h.resolveOverlaps(tx, activity.UserID, activity.ID, activity.StartTime, activity.EndTime, overlapQuery.Overlap); err != nil {
+			return err
+		}
+		if err := tx.Model(&activity).Select("date", "end_time", "duration", "updated_at").Updates(&activity).Error; err != nil {
+			return err
+		}
+		return h.recordRevision(tx, before, c.MustGet("user").(models.User).ID)
+	})
+	if err != nil {
+		respondSaveError(c, err, "Failed to stop activity")
+		return
+	}
+
+	if err := h.db.Preload("Category").Preload("Tags").First(&activity, activity.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to reload activity data",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Activity stopped successfully",
+		activity,
+		nil,
+	))
+}
+
+// GetCurrentActivity returns the user's running activity, if any
+func (h *Handler) GetCurrentActivity(c *gin.Context) {
+	user, _ := c.Get("user")
+
+	activity, err := findRunningActivity(h.db.Preload("Category").Preload("Tags"), user.(models.User).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch running activity",
+			err.Error(),
+		))
+		return
+	}
+
+	if activity == nil {
+		c.JSON(http.StatusOK, types.NewSuccessResponse(
+			"No activity is currently running",
+			nil,
+			nil,
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Running activity retrieved successfully",
+		activity,
+		nil,
+	))
+}
@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"dailyact/models"
+	"dailyact/types"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetTrash lists the current user's deleted activities, most recently deleted first
+func (h *Handler) GetTrash(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var query types.PaginationQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid pagination parameters",
+			err.Error(),
+		))
+		return
+	}
+
+	db := h.db.Unscoped().Model(&models.Activity{}).
+		Where("activities.user_id = ? AND activities.deleted_at IS NOT NULL", user.ID)
+
+	var total int64
+	if err := db.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to count deleted activities",
+			err.Error(),
+		))
+		return
+	}
+
+	var activities []models.Activity
+	offset := (query.Page - 1) * query.PageSize
+	if err := db.Preload("Category").Preload("Tags").
+		Order("activities.deleted_at DESC, activities.id DESC").
+		Offset(offset).Limit(query.PageSize).
+		Find(&activities).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to fetch deleted activities",
+			err.Error(),
+		))
+		return
+	}
+
+	pagination := types.NewPaginationResponse(query.Page, query.PageSize, total)
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Deleted activities retrieved successfully",
+		activities,
+		&pagination,
+	))
+}
+
+// RestoreActivity moves an activity out of the trash. Activities created since it was
+// deleted may overlap it, they are handled with the overlap policy like on creation.
+func (h *Handler) RestoreActivity(c *gin.Context) {
+	var activity models.Activity
+	if err := h.db.Unscoped().First(&activity, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Activity not found",
+			err.Error(),
+		))
+		return
+	}
+
+	if !activity.DeletedAt.Valid {
+		c.JSON(http.StatusNotFound, types.NewErrorResponse(
+			"NOT_FOUND",
+			"Activity not found in trash",
+			"The activity has not been deleted",
+		))
+		return
+	}
+
+	var overlapQuery types.OverlapQuery
+	if err := c.ShouldBindQuery(&overlapQuery); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_QUERY",
+			"Invalid overlap policy",
+			err.Error(),
+		))
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := lockUser(tx, activity.UserID); err != nil {
+			return err
+		}
+		if err := h.resolveOverlaps(tx, activity.UserID, activity.ID, activity.StartTime, activity.EndTime, overlapQuery.Overlap); err != nil {
+			return err
+		}
+		return tx.Unscoped().Model(&activity).UpdateColumn("deleted_at", nil).Error
+	})
+	if err != nil {
+		respondSaveError(c, err, "Failed to restore activity")
+		return
+	}
+
+	if err := h.db.Preload("Category").Preload("Tags").First(&activity, activity.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to reload activity data",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Activity restored successfully",
+		activity,
+		nil,
+	))
+}
+
+// purgeActivities permanently deletes the activities whose IDs are selected by ids,
+// together with their tag links, search tokens, revisions and attachments. It returns
+// the storage keys of the attachment contents, to delete once the transaction committed.
+func purgeActivities(tx *gorm.DB, ids *gorm.DB) ([]string, error) {
+	var keys []string
+	if err := tx.Model(&models.Attachment{}).Where("activity_id IN (?)", ids).Pluck("storage_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Exec("DELETE FROM attachments WHERE activity_id IN (?)", ids).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Exec("DELETE FROM activity_tags WHERE activity_id IN (?)", ids).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Exec("DELETE FROM activity_revisions WHERE activity_id IN (?)", ids).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Exec("DELETE FROM activity_search_tokens WHERE activity_id IN (?)", ids).Error; err != nil {
+		return nil, err
+	}
+	return keys, tx.Exec("DELETE FROM activities WHERE id IN (?)", ids).Error
+}
+
+// PurgeTrash permanently deletes activities that were moved to the trash before cutoff
+func (h *Handler) PurgeTrash(cutoff time.Time) (int64, error) {
+	var purged int64
+	var keys []string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		ids := tx.Unscoped().Model(&models.Activity{}).Select("id").Where("deleted_at < ?", cutoff)
+		if err := tx.Unscoped().Model(&models.Activity{}).Where("deleted_at < ?", cutoff).Count(&purged).Error; err != nil {
+			return err
+		}
+		if purged == 0 {
+			return nil
+		}
+		var err error
+		keys, err = purgeActivities(tx, ids)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	h.deleteStoredAttachments(keys)
+	return purged, nil
+}
+
+// StartTrashPurge purges activities older than retention from the trash every interval,
+// running in the background until the process exits
+func (h *Handler) StartTrashPurge(retention, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			purged, err := h.PurgeTrash(time.Now().Add(-retention))
+			if err != nil {
+				log.Println("Failed to purge trash:", err)
+			} else if purged > 0 {
+				log.Printf("Purged %d activities from trash", purged)
+			}
+			<-ticker.C
+		}
+	}()
+}
@@ -5,6 +5,7 @@ import (
 	"dailyact/types"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -122,3 +123,53 @@ func (h *UserHandler) ChangeRole(c *gin.Context) {
 		nil,
 	))
 }
+
+// ChangeTimezone sets the current user's time zone and re-files their activities under the local day
+func (h *UserHandler) ChangeTimezone(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	var req models.ChangeTimezoneRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_REQUEST",
+			"Invalid request body",
+			err.Error(),
+		))
+		return
+	}
+
+	if _, err := time.LoadLocation(req.Timezone); err != nil || req.Timezone == "Local" {
+		c.JSON(http.StatusBadRequest, types.NewErrorResponse(
+			"INVALID_TIMEZONE",
+			"Invalid time zone",
+			"Time zone must be an IANA name such as Asia/Jakarta",
+		))
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("timezone", req.Timezone).Error; err != nil {
+			return err
+		}
+
+		// Backfill the date of existing activities in the new time zone
+		return tx.Exec(
+			"UPDATE activities SET date = date_trunc('day', start_time AT TIME ZONE ?) AT TIME ZONE 'UTC' WHERE user_id = ?",
+			req.Timezone, user.ID,
+		).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, types.NewErrorResponse(
+			"DB_ERROR",
+			"Failed to update time zone",
+			err.Error(),
+		))
+		return
+	}
+
+	c.JSON(http.StatusOK, types.NewSuccessResponse(
+		"Time zone updated successfully",
+		user,
+		nil,
+	))
+}
@@ -0,0 +1,348 @@
+package ical
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxLineLength caps an unfolded content line, so a malformed file cannot grow one without bound
+const maxLineLength = 1 << 20
+
+// InvalidEvent is a VEVENT that could not be read
+type InvalidEvent struct {
+	Line int // line of its BEGIN:VEVENT
+	UID  string
+	Err  error
+}
+
+// contentLine is an unfolded content line split into its parts
+type contentLine struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Read decodes the events of a calendar. Times without a time zone, or with a TZID that
+// is not a known IANA zone, are read in loc. Events that cannot be read are returned as
+// invalid events, a file that is not a calendar is an error.
+func Read(r io.Reader, loc *time.Location) ([]Event, []InvalidEvent, error) {
+	lines, err := unfold(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(lines) == 0 || !strings.EqualFold(lines[0].text, "BEGIN:VCALENDAR") {
+		return nil, nil, errors.New("not an iCalendar file, BEGIN:VCALENDAR is missing")
+	}
+
+	var events []Event
+	var invalid []InvalidEvent
+	var event *Event
+	var eventErr error
+	var components []string
+	for _, numbered := range lines {
+		line, err := parseContentLine(numbered.text)
+		if err != nil {
+			if event != nil && eventErr == nil {
+				eventErr = fmt.Errorf("line %d: %w", numbered.number, err)
+			}
+			continue
+		}
+
+		switch line.Name {
+		case "BEGIN":
+			components = append(components, strings.ToUpper(line.Value))
+			if len(components) == 2 && components[0] == "VCALENDAR" && components[1] == "VEVENT" {
+				event, eventErr = &Event{Line: numbered.number}, nil
+			}
+			continue
+		case "END":
+			if len(components) == 0 || components[len(components)-1] != strings.ToUpper(line.Value) {
+				return nil, nil, fmt.Errorf("line %d: unexpected END:%s", numbered.number, line.Value)
+			}
+			if event != nil && len(components) == 2 {
+				if eventErr == nil {
+					eventErr = event.validate()
+				}
+				if eventErr != nil {
+					invalid = append(invalid, InvalidEvent{Line: event.Line, UID: event.UID, Err: eventErr})
+				} else {
+					events = append(events, *event)
+				}
+				event = nil
+			}
+			components = components[:len(components)-1]
+			continue
+		}
+
+		// Only the properties of the event itself count, not those of its alarms
+		if event == nil || len(components) != 2 || eventErr != nil {
+			continue
+		}
+		eventErr = event.setProperty(line, loc)
+	}
+
+	if len(components) > 0 {
+		return nil, nil, errors.New("the calendar is not complete, END:VCALENDAR is missing")
+	}
+	return events, invalid, nil
+}
+
+// numberedLine is an unfolded line with the number of its first physical line
+type numberedLine struct {
+	number int
+	text   string
+}
+
+// unfold joins folded lines, which continue with a leading space or tab
+func unfold(r io.Reader) ([]numberedLine, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), maxLineLength)
+	var lines []numberedLine
+	number := 0
+	for scanner.Scan() {
+		number++
+		text := strings.TrimRight(scanner.Text(), "\r")
+		if number == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if text == "" {
+			continue
+		}
+		if (text[0] == ' ' || text[0] == '\t') && len(lines) > 0 {
+			last := &lines[len(lines)-1]
+			if len(last.text)+len(text) > maxLineLength {
+				return nil, fmt.Errorf("line %d is too long", last.number)
+			}
+			last.text += text[1:]
+			continue
+		}
+		lines = append(lines, numberedLine{number: number, text: text})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return lines, nil
+}
+
+// parseContentLine splits name;param=value;param="quoted value":value
+func parseContentLine(text string) (contentLine, error) {
+	line := contentLine{Params: map[string]string{}}
+	i := strings.IndexAny(text, ";:")
+	if i <= 0 {
+		return line, fmt.Errorf("invalid content line %q", text)
+	}
+	line.Name = strings.ToUpper(text[:i])
+
+	for text[i] == ';' {
+		rest := text[i+1:]
+		eq := strings.IndexByte(rest, '=')
+		if eq <= 0 {
+			return line, fmt.Errorf("invalid parameter in %q", text)
+		}
+		name := strings.ToUpper(rest[:eq])
+		rest = rest[eq+1:]
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return line, fmt.Errorf("unterminated quote in %q", text)
+			}
+			value = rest[1 : end+1]
+			rest = rest[end+2:]
+		} else {
+			end := strings.IndexAny(rest, ";:")
+			if end < 0 {
+				return line, fmt.Errorf("missing value in %q", text)
+			}
+			value = rest[:end]
+			rest = rest[end:]
+		}
+		line.Params[name] = value
+		i = len(text) - len(rest)
+		if i >= len(text) {
+			return line, fmt.Errorf("missing value in %q", text)
+		}
+	}
+	if text[i] != ':' {
+		return line, fmt.Errorf("missing value in %q", text)
+	}
+	line.Value = text[i+1:]
+	return line, nil
+}
+
+// setProperty reads one property of the event
+func (e *Event) setProperty(line contentLine, loc *time.Location) error {
+	var err error
+	switch line.Name {
+	case "UID":
+		e.UID = UnescapeText(line.Value)
+	case "DTSTAMP":
+		e.Stamp, _, err = parseDateTime(line, loc)
+	case "DTSTART":
+		e.Start, e.AllDay, err = parseDateTime(line, loc)
+	case "DTEND":
+		e.End, _, err = parseDateTime(line, loc)
+	case "DURATION":
+		e.Duration, err = ParseDuration(line.Value)
+	case "SUMMARY":
+		e.Summary = UnescapeText(line.Value)
+	case "DESCRIPTION":
+		e.Description = UnescapeText(line.Value)
+	case "CATEGORIES":
+		e.Categories = append(e.Categories, splitText(line.Value)...)
+	case "STATUS":
+		e.Status = strings.ToUpper(line.Value)
+	case "RRULE":
+		e.RRule = line.Value
+	case "EXDATE":
+		for _, value := range strings.Split(line.Value, ",") {
+			exdate, _, exErr := parseDateTime(contentLine{Params: line.Params, Value: value}, loc)
+			if exErr != nil {
+				return exErr
+			}
+			e.ExDates = append(e.ExDates, exdate)
+		}
+	case "RECURRENCE-ID":
+		e.RecurrenceID, _, err = parseDateTime(line, loc)
+	}
+	if err != nil {
+		return fmt.Errorf("%s: %w", line.Name, err)
+	}
+	return nil
+}
+
+// validate checks that the event has what is needed to place it in time. An event with
+// neither an end nor a duration lasts one day when it is all-day, and no time otherwise.
+func (e *Event) validate() error {
+	if e.Start.IsZero() {
+		return errors.New("DTSTART is missing")
+	}
+	if e.End.IsZero() {
+		switch {
+		case e.Duration != 0:
+			e.End = e.Start.Add(e.Duration)
+		case e.AllDay:
+			e.End = e.Start.AddDate(0, 0, 1)
+		default:
+			e.End = e.Start
+		}
+	}
+	e.Duration = e.End.Sub(e.Start)
+	if e.Duration < 0 {
+		return errors.New("DTEND is before DTSTART")
+	}
+	return nil
+}
+
+// parseDateTime reads a DATE or DATE-TIME value. all is true for a DATE.
+func parseDateTime(line contentLine, loc *time.Location) (t time.Time, all bool, err error) {
+	value := strings.TrimSpace(line.Value)
+	if tzid := line.Params["TZID"]; tzid != "" {
+		if zone, zoneErr := time.LoadLocation(strings.TrimPrefix(tzid, "/")); zoneErr == nil {
+			loc = zone
+		}
+	}
+
+	if strings.EqualFold(line.Params["VALUE"], "DATE") || len(value) == len("20060102") {
+		t, err = time.ParseInLocation("20060102", value, loc)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err = time.Parse(dateTimeFormat, value)
+		return t, false, err
+	}
+	t, err = time.ParseInLocation("20060102T150405", value, loc)
+	return t, false, err
+}
+
+// ParseDuration reads a DURATION value such as PT1H30M, P1D or -P1W. Days and weeks
+// count as 24 hours.
+func ParseDuration(value string) (time.Duration, error) {
+	invalid := fmt.Errorf("invalid duration %q", value)
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(value, "-"):
+		sign, value = -1, value[1:]
+	case strings.HasPrefix(value, "+"):
+		value = value[1:]
+	}
+	if !strings.HasPrefix(value, "P") || len(value) < 3 {
+		return 0, invalid
+	}
+
+	var total time.Duration
+	inTime := false
+	number := ""
+	for _, char := range value[1:] {
+		switch {
+		case char >= '0' && char <= '9':
+			number += string(char)
+			continue
+		case char == 'T' && number == "" && !inTime:
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(number)
+		if err != nil {
+			return 0, invalid
+		}
+		unit := map[rune]time.Duration{'W': 7 * 24 * time.Hour, 'D': 24 * time.Hour}
+		if inTime {
+			unit = map[rune]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second}
+		}
+		size, ok := unit[char]
+		if !ok {
+			return 0, invalid
+		}
+		total += time.Duration(n) * size
+		number = ""
+	}
+	if number != "" {
+		return 0, invalid
+	}
+	return sign * total, nil
+}
+
+// UnescapeText reverses EscapeText
+func UnescapeText(value string) string {
+	var out strings.Builder
+	escaped := false
+	for _, char := range value {
+		if escaped {
+			if char == 'n' || char == 'N' {
+				out.WriteByte('\n')
+			} else {
+				out.WriteRune(char)
+			}
+			escaped = false
+			continue
+		}
+		if char == '\\' {
+			escaped = true
+			continue
+		}
+		out.WriteRune(char)
+	}
+	return out.String()
+}
+
+// splitText splits a list of TEXT values on the commas that are not escaped
+func splitText(value string) []string {
+	var values []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, UnescapeText(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, UnescapeText(value[start:]))
+}
@@ -0,0 +1,278 @@
+package ical
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Duration
+		err   bool
+	}{
+		{value: "PT1H30M", want: 90 * time.Minute},
+		{value: "PT45S", want: 45 * time.Second},
+		{value: "P1D", want: 24 * time.Hour},
+		{value: "P1DT2H", want: 26 * time.Hour},
+		{value: "P2W", want: 14 * 24 * time.Hour},
+		{value: "-PT15M", want: -15 * time.Minute},
+		{value: "+PT15M", want: 15 * time.Minute},
+		{value: "", err: true},
+		{value: "P", err: true},
+		{value: "PT", err: true},
+		{value: "1H", err: true},
+		{value: "PT1H30", err: true},
+		{value: "P1H", err: true},
+		{value: "PT1D", err: true},
+		{value: "PTT1H", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseDuration(tt.value)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseDuration(%q) = %v, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseDuration(%q) = %v, %v, want %v", tt.value, got, err, tt.want)
+		}
+	}
+}
+
+func TestUnescapeText(t *testing.T) {
+	tests := map[string]string{
+		`plain`:              "plain",
+		`a\, b\; c`:          "a, b; c",
+		`line\nbreak\Nagain`: "line\nbreak\nagain",
+		`back\\slash`:        `back\slash`,
+	}
+	for value, want := range tests {
+		if got := UnescapeText(value); got != want {
+			t.Errorf("UnescapeText(%q) = %q, want %q", value, got, want)
+		}
+		if got := UnescapeText(EscapeText(want)); got != want {
+			t.Errorf("UnescapeText(EscapeText(%q)) = %q", want, got)
+		}
+	}
+}
+
+// calendar wraps content lines in a VCALENDAR with CRLF line ends
+func calendar(lines ...string) string {
+	all := append([]string{"BEGIN:VCALENDAR", "VERSION:2.0"}, lines...)
+	all = append(all, "END:VCALENDAR")
+	return strings.Join(all, "\r\n") + "\r\n"
+}
+
+func TestRead(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		ics     string
+		events  []Event
+		invalid []int // lines of the invalid events
+	}{
+		{
+			name: "utc times and escaped text",
+			ics: calendar(
+				"BEGIN:VEVENT",
+				"UID:a@example.com",
+				"DTSTART:20240301T090000Z",
+				"DTEND:20240301T103000Z",
+				`SUMMARY:Standup\, daily`,
+				`CATEGORIES:Work,Meetings\, internal`,
+				"END:VEVENT",
+			),
+			events: []Event{{
+				UID: "a@example.com", Line: 3, Summary: "Standup, daily",
+				Start: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), End: time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC),
+				Duration: 90 * time.Minute, Categories: []string{"Work", "Meetings, internal"},
+			}},
+		},
+		{
+			name: "floating, zoned and unknown zone times",
+			ics: calendar(
+				"BEGIN:VEVENT",
+				"UID:floating",
+				"DTSTART:20240301T090000",
+				"DURATION:PT30M",
+				"END:VEVENT",
+				"BEGIN:VEVENT",
+				"UID:zoned",
+				"DTSTART;TZID=America/New_York:20240301T090000",
+				"DTEND;TZID=America/New_York:20240301T100000",
+				"END:VEVENT",
+				"BEGIN:VEVENT",
+				"UID:unknown",
+				`DTSTART;TZID="Custom Zone":20240301T090000`,
+				`DTEND;TZID="Custom Zone":20240301T093000`,
+				"END:VEVENT",
+			),
+			events: []Event{
+				{UID: "floating", Line: 3, Start: time.Date(2024, 3, 1, 9, 0, 0, 0, berlin), End: time.Date(2024, 3, 1, 9, 30, 0, 0, berlin), Duration: 30 * time.Minute},
+				{UID: "zoned", Line: 8, Start: time.Date(2024, 3, 1, 9, 0, 0, 0, newYork), End: time.Date(2024, 3, 1, 10, 0, 0, 0, newYork), Duration: time.Hour},
+				{UID: "unknown", Line: 13, Start: time.Date(2024, 3, 1, 9, 0, 0, 0, berlin), End: time.Date(2024, 3, 1, 9, 30, 0, 0, berlin), Duration: 30 * time.Minute},
+			},
+		},
+		{
+			name: "all-day event across the DST change",
+			ics: calendar(
+				"BEGIN:VEVENT",
+				"UID:day",
+				"DTSTART;VALUE=DATE:20240331",
+				"END:VEVENT",
+			),
+			events: []Event{{
+				UID: "day", Line: 3, AllDay: true,
+				Start: time.Date(2024, 3, 31, 0, 0, 0, 0, berlin), End: time.Date(2024, 4, 1, 0, 0, 0, 0, berlin),
+				Duration: 23 * time.Hour,
+			}},
+		},
+		{
+			name: "folded lines, recurrence and alarms",
+			ics: calendar(
+				"BEGIN:VEVENT",
+				"UID:weekly",
+				"DTSTART:20240301T090000Z",
+				"DTEND:20240301T100000Z",
+				"SUMMARY:A long",
+				"  summary",
+				"RRULE:FREQ=WEEKLY;COUNT=3",
+				"EXDATE:20240308T090000Z,20240315T090000Z",
+				"BEGIN:VALARM",
+				"DESCRIPTION:not the event",
+				"TRIGGER:-PT5M",
+				"END:VALARM",
+				"END:VEVENT",
+			),
+			events: []Event{{
+				UID: "weekly", Line: 3, Summary: "A long summary", RRule: "FREQ=WEEKLY;COUNT=3",
+				Start: time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), End: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+				Duration: time.Hour,
+				ExDates:  []time.Time{time.Date(2024, 3, 8, 9, 0, 0, 0, time.UTC), time.Date(2024, 3, 15, 9, 0, 0, 0, time.UTC)},
+			}},
+		},
+		{
+			name: "invalid events",
+			ics: calendar(
+				"BEGIN:VEVENT",
+				"UID:no-start",
+				"END:VEVENT",
+				"BEGIN:VEVENT",
+				"UID:backwards",
+				"DTSTART:20240301T100000Z",
+				"DTEND:20240301T090000Z",
+				"END:VEVENT",
+				"BEGIN:VEVENT",
+				"UID:bad-time",
+				"DTSTART:2024-03-01",
+				"END:VEVENT",
+				"BEGIN:VEVENT",
+				"UID:bad-line",
+				"no colon here",
+				"DTSTART:20240301T100000Z",
+				"END:VEVENT",
+				"BEGIN:VEVENT",
+				"UID:good",
+				"DTSTART:20240301T100000Z",
+				"END:VEVENT",
+			),
+			events: []Event{{
+				UID: "good", Line: 20,
+				Start: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC), End: time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+			}},
+			invalid: []int{3, 6, 11, 15},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			events, invalid, err := Read(strings.NewReader(tt.ics), berlin)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(events) != len(tt.events) {
+				t.Fatalf("got %d events, want %d", len(events), len(tt.events))
+			}
+			for i, got := range events {
+				want := tt.events[i]
+				if got.UID != want.UID || got.Line != want.Line || got.Summary != want.Summary ||
+					got.AllDay != want.AllDay || got.Duration != want.Duration || got.RRule != want.RRule ||
+					!got.Start.Equal(want.Start) || !got.End.Equal(want.End) {
+					t.Errorf("event %d:\n got %+v\nwant %+v", i, got, want)
+				}
+				if strings.Join(got.Categories, "|") != strings.Join(want.Categories, "|") {
+					t.Errorf("event %d: categories %q, want %q", i, got.Categories, want.Categories)
+				}
+				if len(got.ExDates) != len(want.ExDates) {
+					t.Errorf("event %d: exdates %v, want %v", i, got.ExDates, want.ExDates)
+				}
+				for j := range got.ExDates {
+					if j < len(want.ExDates) && !got.ExDates[j].Equal(want.ExDates[j]) {
+						t.Errorf("event %d: exdate %d is %v, want %v", i, j, got.ExDates[j], want.ExDates[j])
+					}
+				}
+			}
+			if len(invalid) != len(tt.invalid) {
+				t.Fatalf("got invalid events %+v, want lines %v", invalid, tt.invalid)
+			}
+			for i, event := range invalid {
+				if event.Line != tt.invalid[i] || event.Err == nil {
+					t.Errorf("invalid event %d: %+v, want line %d with an error", i, event, tt.invalid[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadRejectsFile(t *testing.T) {
+	tests := map[string]string{
+		"not a calendar":  "BEGIN:VEVENT\r\nEND:VEVENT\r\n",
+		"empty":           "",
+		"unclosed":        "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART:20240301T100000Z\r\nEND:VEVENT\r\n",
+		"mismatched end":  "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VTODO\r\nEND:VCALENDAR\r\n",
+		"line over limit": "BEGIN:VCALENDAR\r\nX-LONG:" + strings.Repeat("x", maxLineLength) + "\r\nEND:VCALENDAR\r\n",
+	}
+	for name, ics := range tests {
+		if _, _, err := Read(strings.NewReader(ics), time.UTC); err == nil {
+			t.Errorf("%s: want an error", name)
+		}
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	events := []Event{{
+		UID:         "1@dailyact",
+		Stamp:       time.Date(2024, 3, 2, 8, 0, 0, 0, time.UTC),
+		Start:       time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+		End:         time.Date(2024, 3, 1, 10, 0, 0, 0, time.UTC),
+		Summary:     "Standup; daily, " + strings.Repeat("ü", 60),
+		Description: "line one\nline two",
+		Categories:  []string{"Work"},
+	}}
+	var out bytes.Buffer
+	if err := (Calendar{ProductID: "-//DailyAct//Test//EN", Events: events}).Write(&out); err != nil {
+		t.Fatal(err)
+	}
+	read, invalid, err := Read(&out, time.UTC)
+	if err != nil || len(invalid) != 0 || len(read) != 1 {
+		t.Fatalf("got %+v, %+v, %v", read, invalid, err)
+	}
+	got, want := read[0], events[0]
+	if got.UID != want.UID || got.Summary != want.Summary || got.Description != want.Description ||
+		!got.Start.Equal(want.Start) || !got.End.Equal(want.End) || !got.Stamp.Equal(want.Stamp) ||
+		strings.Join(got.Categories, "|") != "Work" {
+		t.Errorf("round trip:\n got %+v\nwant %+v", got, want)
+	}
+}
@@ -0,0 +1,114 @@
+// Package ical reads and writes the parts of iCalendar (RFC 5545) used for activities
+package ical
+
+import (
+	"bufio"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Event is a VEVENT of a calendar
+type Event struct {
+	UID         string
+	Stamp       time.Time // DTSTAMP, when the event was last changed
+	Start       time.Time
+	End         time.Time
+	Summary     string
+	Description string
+	Categories  []string
+
+	// Set by Read only
+	Line         int // line of BEGIN:VEVENT in the file
+	AllDay       bool
+	Duration     time.Duration
+	Status       string      // e.g. CONFIRMED or CANCELLED
+	RRule        string      // recurrence rule, see package rrule
+	ExDates      []time.Time // occurrences removed from the recurrence
+	RecurrenceID time.Time   // set on an event replacing one occurrence of a recurring event
+}
+
+// Calendar is a VCALENDAR holding events
+type Calendar struct {
+	ProductID string // PRODID, e.g. -//DailyAct//Activities//EN
+	Name      string // X-WR-CALNAME, shown by calendar apps
+	Events    []Event
+}
+
+// maxLineOctets is the longest content line allowed before folding
+const maxLineOctets = 75
+
+// dateTimeFormat is the UTC form of DATE-TIME values
+const dateTimeFormat = "20060102T150405Z"
+
+// Write encodes the calendar to w
+func (cal Calendar) Write(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	write := func(name, value string) {
+		writeLine(out, name+":"+value)
+	}
+
+	write("BEGIN", "VCALENDAR")
+	write("VERSION", "2.0")
+	write("PRODID", cal.ProductID)
+	write("CALSCALE", "GREGORIAN")
+	write("METHOD", "PUBLISH")
+	if cal.Name != "" {
+		write("X-WR-CALNAME", EscapeText(cal.Name))
+	}
+
+	for _, event := range cal.Events {
+		write("BEGIN", "VEVENT")
+		write("UID", EscapeText(event.UID))
+		write("DTSTAMP", event.Stamp.UTC().Format(dateTimeFormat))
+		write("DTSTART", event.Start.UTC().Format(dateTimeFormat))
+		write("DTEND", event.End.UTC().Format(dateTimeFormat))
+		write("SUMMARY", EscapeText(event.Summary))
+		if event.Description != "" {
+			write("DESCRIPTION", EscapeText(event.Description))
+		}
+		if len(event.Categories) > 0 {
+			categories := make([]string, len(event.Categories))
+			for i, category := range event.Categories {
+				categories[i] = EscapeText(category)
+			}
+			write("CATEGORIES", strings.Join(categories, ","))
+		}
+		write("END", "VEVENT")
+	}
+
+	write("END", "VCALENDAR")
+	return out.Flush()
+}
+
+// EscapeText escapes a TEXT value
+func EscapeText(value string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", `\n`,
+	).Replace(value)
+}
+
+// writeLine writes a content line, folded so that no line exceeds 75 octets.
+// Lines are only split between UTF-8 characters.
+func writeLine(out *bufio.Writer, line string) {
+	limit := maxLineOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		out.WriteString(line[:cut])
+		out.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space that counts towards their length
+		limit = maxLineOctets - 1
+	}
+	out.WriteString(line)
+	out.WriteString("\r\n")
+}
@@ -6,8 +6,12 @@ import (
 	"dailyact/middleware"
 	"dailyact/models"
 	"dailyact/seeds"
+	"dailyact/storage"
 	"log"
 	"os"
+	"strconv"
+	"time"
+	_ "time/tzdata" // user time zones must resolve even without system zoneinfo
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -35,14 +39,52 @@ func main() {
 		return
 	}
 
-	handler, _ := handlers.NewHandler(db, encryptionService)
+	blindIndexService, err := models.NewBlindIndexService()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	// Attachment contents are kept encrypted on the local disk
+	attachmentDir := os.Getenv("ATTACHMENT_DIR")
+	if attachmentDir == "" {
+		attachmentDir = "attachments"
+	}
+	attachmentStorage, err := storage.NewLocalStorage(attachmentDir)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	handler, err := handlers.NewHandler(db, encryptionService, blindIndexService, attachmentStorage)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	// Purge deleted activities once they have been in the trash for the retention window
+	retentionDays := 30
+	if value := os.Getenv("TRASH_RETENTION_DAYS"); value != "" {
+		retentionDays, err = strconv.Atoi(value)
+		if err != nil || retentionDays < 1 {
+			log.Println("TRASH_RETENTION_DAYS must be a positive number of days")
+			return
+		}
+	}
+	handler.StartTrashPurge(time.Duration(retentionDays)*24*time.Hour, time.Hour)
+
+	// Turn due occurrences of recurring templates into activities to confirm
+	handler.StartRecurringGeneration(15 * time.Minute)
+
 	authHandler := handlers.NewAuthHandler(db)
 	userHandler := handlers.NewUserHandler(db)
 	mobileAuthHandler := handlers.NewMobileAuthHandler(db)
 	authMiddleware := middleware.NewAuthMiddleware(db)
 
 	// Initialize router
-	r := gin.Default()
+	// Search terms in the q parameter and calendar feed tokens are kept out of the request log
+	r := gin.New()
+	r.Use(middleware.RedactedLogger("q", "token"), gin.Recovery())
 
 	// Add CORS middleware
 	r.Use(middleware.CORSMiddleware())
@@ -53,6 +95,7 @@ func main() {
 		auth.GET("/google/login", authHandler.GoogleLogin)
 		auth.GET("/google/callback", authHandler.GoogleCallback)
 		auth.GET("/me", authMiddleware.RequireAuth(), authHandler.GetMe)
+		auth.PUT("/me/timezone", authMiddleware.RequireAuth(), userHandler.ChangeTimezone)
 		auth.POST("/logout", authMiddleware.RequireAuth(), authHandler.Logout)
 
 		// Mobile auth routes
@@ -78,13 +121,118 @@ func main() {
 
 	// Activity routes
 	// Activities routes (protected)
+	requireActivityTemplateOwnership := authMiddleware.RequireResourceOwnershipOrAdmin("Template", func() models.Owned { return &models.ActivityTemplate{} })
 	activities := r.Group("/activities", authMiddleware.RequireAuth())
 	{
 		activities.POST("", handler.CreateActivity)
 		activities.GET("", handler.GetActivities)
+		activities.POST("/start", handler.StartActivity)
+		activities.GET("/current", handler.GetCurrentActivity)
+		activities.GET("/trash", handler.GetTrash)
+		activities.GET("/export", handler.ExportActivities)
+		activities.POST("/import", handler.ImportActivities)
+		activities.POST("/import/ics", handler.ImportICS)
+		activities.POST("/import/:source/preview", handler.PreviewTrackerImport)
+		activities.POST("/import/:source", handler.ImportTrackerExport)
+		activities.POST("/from-template/:id", requireActivityTemplateOwnership, handler.CreateActivityFromTemplate)
+		activities.POST("/parse", handler.ParseActivity)
+		activities.POST("/batch", handler.BatchActivities)
+		activities.GET("/gaps", handler.GetGaps)
+		activities.POST("/gaps/fill", handler.FillGaps)
+		activities.POST("/:id/restore", authMiddleware.RequireTrashedOwnershipOrAdmin(), handler.RestoreActivity)
+		activities.POST("/:id/confirm", authMiddleware.RequireOwnershipOrAdmin(), handler.ConfirmActivity)
+		activities.POST("/:id/stop", authMiddleware.RequireOwnershipOrAdmin(), handler.StopActivity)
+		activities.GET("/:id/history", authMiddleware.RequireOwnershipOrAdmin(), handler.GetActivityHistory)
+		activities.POST("/:id/revert/:rev", authMiddleware.RequireOwnershipOrAdmin(), handler.RevertActivity)
 		activities.GET("/:id", authMiddleware.RequireOwnershipOrAdmin(), handler.GetActivityByID)
 		activities.PUT("/:id", authMiddleware.RequireOwnershipOrAdmin(), handler.UpdateActivity)
 		activities.DELETE("/:id", authMiddleware.RequireOwnershipOrAdmin(), handler.DeleteActivity)
+		activities.POST("/:id/attachments", authMiddleware.RequireOwnershipOrAdmin(), handler.UploadAttachment)
+		activities.GET("/:id/attachments", authMiddleware.RequireOwnershipOrAdmin(), handler.GetAttachments)
+		activities.GET("/:id/attachments/:attachment_id", authMiddleware.RequireOwnershipOrAdmin(), handler.DownloadAttachment)
+		activities.DELETE("/:id/attachments/:attachment_id", authMiddleware.RequireOwnershipOrAdmin(), handler.DeleteAttachment)
+	}
+
+	// Activity template routes
+	activityTemplates := r.Group("/activity-templates", authMiddleware.RequireAuth())
+	{
+		activityTemplates.POST("", handler.CreateActivityTemplate)
+		activityTemplates.GET("", handler.GetActivityTemplates)
+		activityTemplates.GET("/:id", requireActivityTemplateOwnership, handler.GetActivityTemplateByID)
+		activityTemplates.PUT("/:id", requireActivityTemplateOwnership, handler.UpdateActivityTemplate)
+		activityTemplates.DELETE("/:id", requireActivityTemplateOwnership, handler.DeleteActivityTemplate)
+	}
+
+	// Tag routes
+	requireTagOwnership := authMiddleware.RequireResourceOwnershipOrAdmin("Tag", func() models.Owned { return &models.Tag{} })
+	tags := r.Group("/tags", authMiddleware.RequireAuth())
+	{
+		tags.GET("", handler.GetTags)
+		tags.POST("", handler.CreateTag)
+		tags.PUT("/:id", requireTagOwnership, handler.RenameTag)
+		tags.POST("/:id/merge", requireTagOwnership, handler.MergeTag)
+		tags.DELETE("/:id", requireTagOwnership, handler.DeleteTag)
+	}
+
+	// Goal routes
+	requireGoalOwnership := authMiddleware.RequireResourceOwnershipOrAdmin("Goal", func() models.Owned { return &models.Goal{} })
+	goals := r.Group("/goals", authMiddleware.RequireAuth())
+	{
+		goals.POST("", handler.CreateGoal)
+		goals.GET("", handler.GetGoals)
+		goals.GET("/progress", handler.GetGoalProgress)
+		goals.GET("/:id", requireGoalOwnership, handler.GetGoalByID)
+		goals.PUT("/:id", requireGoalOwnership, handler.UpdateGoal)
+		goals.DELETE("/:id", requireGoalOwnership, handler.DeleteGoal)
+	}
+
+	// Recurring template routes
+	requireRecurringTemplateOwnership := authMiddleware.RequireResourceOwnershipOrAdmin("Recurring template", func() models.Owned { return &models.RecurringTemplate{} })
+	recurringTemplates := r.Group("/recurring-templates", authMiddleware.RequireAuth())
+	{
+		recurringTemplates.POST("", handler.CreateRecurringTemplate)
+		recurringTemplates.GET("", handler.GetRecurringTemplates)
+		recurringTemplates.POST("/generate", handler.GenerateRecurringActivities)
+		recurringTemplates.GET("/:id", requireRecurringTemplateOwnership, handler.GetRecurringTemplateByID)
+		recurringTemplates.PUT("/:id", requireRecurringTemplateOwnership, handler.UpdateRecurringTemplate)
+		recurringTemplates.DELETE("/:id", requireRecurringTemplateOwnership, handler.DeleteRecurringTemplate)
+	}
+
+	// Report routes
+	reports := r.Group("/reports", authMiddleware.RequireAuth())
+	{
+		reports.GET("/daily", handler.GetDailyReport)
+		reports.GET("/weekly", handler.GetWeeklyReport)
+	}
+
+	// Stats routes
+	stats := r.Group("/stats", authMiddleware.RequireAuth())
+	{
+		stats.GET("/streaks", handler.GetStreaks)
+	}
+
+	// Check-in routes
+	requireCheckInOwnership := authMiddleware.RequireResourceOwnershipOrAdmin("Check-in", func() models.Owned { return &models.CheckIn{} })
+	checkIns := r.Group("/checkins", authMiddleware.RequireAuth())
+	{
+		checkIns.POST("", handler.SaveCheckIn)
+		checkIns.GET("", handler.GetCheckIns)
+		checkIns.DELETE("/:id", requireCheckInOwnership, handler.DeleteCheckIn)
+	}
+
+	// Insight routes
+	insights := r.Group("/insights", authMiddleware.RequireAuth())
+	{
+		insights.GET("/mood", handler.GetMoodInsights)
+	}
+
+	// Calendar feed routes, the feed itself is authenticated by its token
+	calendar := r.Group("/calendar")
+	{
+		calendar.GET("/feed", authMiddleware.RequireAuth(), handler.GetCalendarFeed)
+		calendar.POST("/feed", authMiddleware.RequireAuth(), handler.CreateCalendarFeed)
+		calendar.DELETE("/feed", authMiddleware.RequireAuth(), handler.DeleteCalendarFeed)
+		calendar.GET("/feed.ics", handler.GetCalendarFeedICS)
 	}
 
 	// App feedback routes
@@ -112,6 +112,21 @@ func (m *AuthMiddleware) RequireSuperAdmin() gin.HandlerFunc {
 }
 
 func (m *AuthMiddleware) RequireOwnershipOrAdmin() gin.HandlerFunc {
+	return m.RequireResourceOwnershipOrAdmin("Activity", func() models.Owned { return &models.Activity{} })
+}
+
+// RequireTrashedOwnershipOrAdmin is RequireOwnershipOrAdmin for activities in the trash
+func (m *AuthMiddleware) RequireTrashedOwnershipOrAdmin() gin.HandlerFunc {
+	return requireOwnership(m.db.Unscoped(), "Activity", func() models.Owned { return &models.Activity{} })
+}
+
+// RequireResourceOwnershipOrAdmin checks that the resource identified by the id
+// parameter belongs to the current user. newResource returns the model to load it into.
+func (m *AuthMiddleware) RequireResourceOwnershipOrAdmin(name string, newResource func() models.Owned) gin.HandlerFunc {
+	return requireOwnership(m.db, name, newResource)
+}
+
+func requireOwnership(db *gorm.DB, name string, newResource func() models.Owned) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user, exists := c.Get("user")
 		if !exists {
@@ -123,34 +138,34 @@ func (m *AuthMiddleware) RequireOwnershipOrAdmin() gin.HandlerFunc {
 			return
 		}
 
-		// Admin can access all activities
+		// Admin can access all resources
 		if user.(models.User).Role == models.RoleAdmin {
 			c.Next()
 			return
 		}
 
 		// For non-admin users, check ownership
-		activityID := c.Param("id")
-		if activityID == "" {
+		resourceID := c.Param("id")
+		if resourceID == "" {
 			c.Next() // For list endpoints, filtering will be done in the handler
 			return
 		}
 
-		var activity models.Activity
-		if err := m.db.First(&activity, activityID).Error; err != nil {
+		resource := newResource()
+		if err := db.First(resource, resourceID).Error; err != nil {
 			c.AbortWithStatusJSON(http.StatusNotFound, types.NewErrorResponse(
 				"NOT_FOUND",
-				"Activity not found",
+				name+" not found",
 				err.Error(),
 			))
 			return
 		}
 
-		if activity.UserID != user.(models.User).ID {
+		if resource.OwnerID() != user.(models.User).ID {
 			c.AbortWithStatusJSON(http.StatusForbidden, types.NewErrorResponse(
 				"FORBIDDEN",
 				"Access denied",
-				"You don't have permission to access this activity",
+				"You don't have permission to access this "+strings.ToLower(name),
 			))
 			return
 		}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// ActivityTemplate is a user's favorite activity, e.g. "Morning run / Fitness / 30 min",
+// from which an activity is logged in one tap
+type ActivityTemplate struct {
+	ID              uint            `json:"id" gorm:"primaryKey"`
+	UserID          uint            `json:"user_id" gorm:"not null;uniqueIndex:idx_activity_templates_user_name"`
+	Name            string          `json:"name" gorm:"type:varchar(100);not null;uniqueIndex:idx_activity_templates_user_name"`
+	CategoryID      uint            `json:"category_id" gorm:"not null"`
+	Category        Category        `json:"category" gorm:"foreignKey:CategoryID"`
+	Description     EncryptedString `json:"description" gorm:"type:text;not null"`
+	Notes           EncryptedString `json:"notes" gorm:"type:text"`
+	DefaultDuration int             `json:"default_duration" gorm:"not null"` // in second
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+type ActivityTemplateRequest struct {
+	Name            string `json:"name" binding:"required,max=100"`
+	CategoryID      uint   `json:"category_id" binding:"required"`
+	Description     string `json:"description" binding:"required"`
+	Notes           string `json:"notes"`
+	DefaultDuration int    `json:"default_duration" binding:"required,min=1,max=86400"` // in second, at most a day
+}
+
+func (t *ActivityTemplate) OwnerID() uint {
+	return t.UserID
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// Attachment is a file attached to an activity. The content lives encrypted in the
+// attachment storage under StorageKey, the file name is encrypted like activity fields.
+type Attachment struct {
+	ID          uint            `json:"id" gorm:"primaryKey"`
+	ActivityID  uint            `json:"activity_id" gorm:"not null;index"`
+	UserID      uint            `json:"user_id" gorm:"not null"`
+	FileName    EncryptedString `json:"file_name" gorm:"type:text;not null"`
+	ContentType string          `json:"content_type" gorm:"type:varchar(100);not null"`
+	Size        int64           `json:"size" gorm:"not null"` // in bytes, before encryption
+	StorageKey  string          `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+func (a *Attachment) OwnerID() uint {
+	return a.UserID
+}
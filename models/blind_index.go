@@ -0,0 +1,80 @@
+package models
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// BlindIndexService derives search tokens from plaintext. Tokens are keyed HMACs of
+// the words, so equal words give equal tokens while the words stay unreadable.
+type BlindIndexService struct {
+	key []byte
+}
+
+// ActivitySearchToken links an activity to the blind index token of one of its words
+type ActivitySearchToken struct {
+	ActivityID uint   `gorm:"primaryKey"`
+	Token      string `gorm:"primaryKey;type:char(32);index"`
+}
+
+// NewBlindIndexService creates a new blind index service using the BLIND_INDEX_KEY environment variable
+func NewBlindIndexService() (*BlindIndexService, error) {
+	keyStr := os.Getenv("BLIND_INDEX_KEY")
+	if keyStr == "" {
+		return nil, errors.New("BLIND_INDEX_KEY environment variable not set")
+	}
+
+	// The index key must differ from the encryption key
+	if keyStr == os.Getenv("ENCRYPTION_KEY") {
+		return nil, errors.New("BLIND_INDEX_KEY must be different from ENCRYPTION_KEY")
+	}
+
+	key, err := base64.StdEncoding.DecodeString(keyStr)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(key) != 32 {
+		return nil, errors.New("blind index key must be 32 bytes (256 bits)")
+	}
+
+	return &BlindIndexService{
+		key: key,
+	}, nil
+}
+
+// Tokens returns the distinct tokens of the words in the given texts.
+// Words are lowercased runs of letters and digits, single characters are skipped.
+func (s *BlindIndexService) Tokens(texts ...string) []string {
+	seen := map[string]bool{}
+	tokens := []string{}
+	for _, text := range texts {
+		words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			if len([]rune(word)) < 2 {
+				continue
+			}
+			token := s.token(word)
+			if !seen[token] {
+				seen[token] = true
+				tokens = append(tokens, token)
+			}
+		}
+	}
+	return tokens
+}
+
+// token returns the first 16 bytes of the HMAC-SHA256 of a word, hex encoded
+func (s *BlindIndexService) token(word string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(word))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
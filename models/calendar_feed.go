@@ -0,0 +1,37 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"time"
+)
+
+// CalendarFeed grants read access to a user's activities as an iCalendar feed.
+// Calendar apps cannot send a Bearer token, so the feed URL carries a secret token
+// instead. Only the token's hash is stored, and replacing the feed revokes the old URL.
+type CalendarFeed struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex"`
+	User      User      `json:"-" gorm:"foreignKey:UserID"`
+	TokenHash string    `json:"-" gorm:"type:char(64);not null;uniqueIndex"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewCalendarFeedToken returns a random feed token and the hash to store for it
+func NewCalendarFeedToken() (token, hash string, err error) {
+	raw := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(raw)
+	return token, HashCalendarFeedToken(token), nil
+}
+
+// HashCalendarFeedToken returns the stored form of a feed token
+func HashCalendarFeedToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// CheckIn is the mood and energy a user reports for a whole day, at most one per day
+type CheckIn struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_check_ins_user_date"`
+	Date      time.Time `json:"date" gorm:"type:date;not null;uniqueIndex:idx_check_ins_user_date"` // day in the user's time zone
+	Mood      int       `json:"mood" gorm:"not null;check:chk_check_ins_mood,mood BETWEEN 1 AND 5"`
+	Energy    *int      `json:"energy" gorm:"check:chk_check_ins_energy,energy BETWEEN 1 AND 5"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// CheckInRequest records the check-in of a day. The date defaults to today in the user's time zone.
+type CheckInRequest struct {
+	Date   *string `json:"date"` // YYYY-MM-DD
+	Mood   int     `json:"mood" binding:"required,min=1,max=5"`
+	Energy *int    `json:"energy" binding:"omitempty,min=1,max=5"`
+}
+
+func (c *CheckIn) OwnerID() uint {
+	return c.UserID
+}
@@ -0,0 +1,142 @@
+package models
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	// fileChunkSize is the plaintext size of each encrypted chunk of a file
+	fileChunkSize = 64 * 1024
+	// fileNoncePrefixSize is the random part of the chunk nonces, stored at the start of a file
+	fileNoncePrefixSize = 7
+)
+
+// ErrFileTampered is returned when encrypted file content fails authentication or is truncated
+var ErrFileTampered = errors.New("encrypted file is corrupt or has been tampered with")
+
+// FileEncryptionService encrypts file contents at rest. Files are sealed with AES-GCM in
+// chunks so they can be encrypted and decrypted as streams without loading them whole.
+// Each chunk nonce holds its position and a last chunk flag, so chunks cannot be
+// reordered, dropped or cut off without being detected.
+type FileEncryptionService struct {
+	aead cipher.AEAD
+}
+
+// DeriveKey returns a 32-byte key for purpose derived from the encryption key, so other
+// data can be encrypted without reusing the key of activity fields directly
+func (s *EncryptionService) DeriveKey(purpose string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// NewFileEncryptionService creates a file encryption service with a key derived from the encryption service
+func NewFileEncryptionService(encryptionService *EncryptionService) (*FileEncryptionService, error) {
+	block, err := aes.NewCipher(encryptionService.DeriveKey("dailyact file encryption"))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &FileEncryptionService{aead: aead}, nil
+}
+
+// chunkNonce returns the nonce of the chunk at index
+func (s *FileEncryptionService) chunkNonce(prefix []byte, index uint32, last bool) []byte {
+	nonce := make([]byte, s.aead.NonceSize())
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[fileNoncePrefixSize:], index)
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// Encrypt reads plaintext from src until EOF and writes it encrypted to dst
+func (s *FileEncryptionService) Encrypt(dst io.Writer, src io.Reader) error {
+	prefix := make([]byte, fileNoncePrefixSize)
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return err
+	}
+	if _, err := dst.Write(prefix); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReaderSize(src, fileChunkSize)
+	chunk := make([]byte, fileChunkSize)
+	sealed := make([]byte, 0, fileChunkSize+s.aead.Overhead())
+	for index := uint32(0); ; index++ {
+		n, err := io.ReadFull(reader, chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		// The chunk is the last one when nothing follows it
+		last := err != nil
+		if !last {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				last = true
+			} else if peekErr != nil {
+				return peekErr
+			}
+		}
+
+		sealed = s.aead.Seal(sealed[:0], s.chunkNonce(prefix, index, last), chunk[:n], nil)
+		if _, err := dst.Write(sealed); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// Decrypt reads content written by Encrypt from src and writes the plaintext to dst.
+// Chunks are written as soon as they are authenticated, so on error dst may have
+// received the start of the file.
+func (s *FileEncryptionService) Decrypt(dst io.Writer, src io.Reader) error {
+	prefix := make([]byte, fileNoncePrefixSize)
+	if _, err := io.ReadFull(src, prefix); err != nil {
+		return ErrFileTampered
+	}
+
+	reader := bufio.NewReaderSize(src, fileChunkSize+s.aead.Overhead())
+	sealed := make([]byte, fileChunkSize+s.aead.Overhead())
+	plain := make([]byte, 0, fileChunkSize)
+	for index := uint32(0); ; index++ {
+		n, err := io.ReadFull(reader, sealed)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+
+		last := err != nil
+		if !last {
+			if _, peekErr := reader.Peek(1); peekErr == io.EOF {
+				last = true
+			} else if peekErr != nil {
+				return peekErr
+			}
+		}
+
+		plain, err = s.aead.Open(plain[:0], s.chunkNonce(prefix, index, last), sealed[:n], nil)
+		if err != nil {
+			return ErrFileTampered
+		}
+		if _, err := dst.Write(plain); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
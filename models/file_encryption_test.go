@@ -0,0 +1,92 @@
+package models
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+func newTestFileEncryption(t *testing.T, keyByte byte) *FileEncryptionService {
+	t.Helper()
+	service, err := NewFileEncryptionService(&EncryptionService{key: bytes.Repeat([]byte{keyByte}, 32)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+func encryptBytes(t *testing.T, service *FileEncryptionService, plain []byte) []byte {
+	t.Helper()
+	var sealed bytes.Buffer
+	if err := service.Encrypt(&sealed, bytes.NewReader(plain)); err != nil {
+		t.Fatal(err)
+	}
+	return sealed.Bytes()
+}
+
+func TestFileEncryptionRoundTrip(t *testing.T) {
+	service := newTestFileEncryption(t, 1)
+	overhead := service.aead.Overhead()
+
+	for _, size := range []int{0, 1, fileChunkSize - 1, fileChunkSize, fileChunkSize + 1, 3*fileChunkSize + 17} {
+		plain := make([]byte, size)
+		if _, err := io.ReadFull(rand.Reader, plain); err != nil {
+			t.Fatal(err)
+		}
+		sealed := encryptBytes(t, service, plain)
+
+		chunks := (size + fileChunkSize - 1) / fileChunkSize
+		if chunks == 0 {
+			chunks = 1
+		}
+		if want := fileNoncePrefixSize + size + chunks*overhead; len(sealed) != want {
+			t.Errorf("size %d: sealed to %d bytes, want %d", size, len(sealed), want)
+		}
+
+		var out bytes.Buffer
+		if err := service.Decrypt(&out, bytes.NewReader(sealed)); err != nil {
+			t.Errorf("size %d: %v", size, err)
+			continue
+		}
+		if !bytes.Equal(out.Bytes(), plain) {
+			t.Errorf("size %d: decrypted content differs", size)
+		}
+	}
+}
+
+func TestFileEncryptionTampering(t *testing.T) {
+	service := newTestFileEncryption(t, 1)
+	chunk := fileChunkSize + service.aead.Overhead()
+	plain := bytes.Repeat([]byte("0123456789abcdef"), 3*fileChunkSize/16)
+	sealed := encryptBytes(t, service, plain)
+	body := sealed[fileNoncePrefixSize:]
+
+	concat := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+	flipped := append([]byte{}, sealed...)
+	flipped[len(flipped)/2] ^= 1
+
+	tests := map[string][]byte{
+		"flipped bit":         flipped,
+		"empty":               {},
+		"prefix only":         sealed[:fileNoncePrefixSize],
+		"last chunk dropped":  sealed[:fileNoncePrefixSize+2*chunk],
+		"cut inside a chunk":  sealed[:len(sealed)-1],
+		"chunks reordered":    concat(sealed[:fileNoncePrefixSize], body[chunk:2*chunk], body[:chunk], body[2*chunk:]),
+		"chunk repeated":      concat(sealed, body[2*chunk:]),
+		"other nonce prefix":  concat([]byte{0, 0, 0, 0, 0, 0, 0}, body),
+		"other file appended": concat(sealed, encryptBytes(t, service, []byte("x"))),
+	}
+	for name, content := range tests {
+		if err := service.Decrypt(io.Discard, bytes.NewReader(content)); !errors.Is(err, ErrFileTampered) {
+			t.Errorf("%s: got %v, want ErrFileTampered", name, err)
+		}
+	}
+
+	if err := newTestFileEncryption(t, 2).Decrypt(io.Discard, bytes.NewReader(sealed)); !errors.Is(err, ErrFileTampered) {
+		t.Errorf("other key: got %v, want ErrFileTampered", err)
+	}
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"time"
+)
+
+type GoalKind string
+
+const (
+	GoalMin GoalKind = "min" // spend at least the target
+	GoalMax GoalKind = "max" // spend at most the target
+)
+
+type GoalPeriod string
+
+const (
+	GoalDaily   GoalPeriod = "daily"
+	GoalWeekly  GoalPeriod = "weekly"
+	GoalMonthly GoalPeriod = "monthly"
+)
+
+// Goal is a user's time target for a category, e.g. Sleep at least 8h per day
+type Goal struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	UserID        uint       `json:"user_id" gorm:"not null;index"`
+	CategoryID    uint       `json:"category_id" gorm:"not null"`
+	Category      Category   `json:"category" gorm:"foreignKey:CategoryID"`
+	Kind          GoalKind   `json:"kind" gorm:"type:varchar(10);not null"`
+	Period        GoalPeriod `json:"period" gorm:"type:varchar(10);not null"`
+	TargetSeconds int        `json:"target_seconds" gorm:"not null"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type GoalRequest struct {
+	CategoryID    uint       `json:"category_id" binding:"required"`
+	Kind          GoalKind   `json:"kind" binding:"required,oneof=min max"`
+	Period        GoalPeriod `json:"period" binding:"required,oneof=daily weekly monthly"`
+	TargetSeconds int        `json:"target_seconds" binding:"required,min=1"`
+}
+
+// GoalProgress is the attainment of a goal in its current period
+type GoalProgress struct {
+	Goal          Goal      `json:"goal"`
+	PeriodStart   time.Time `json:"period_start"`
+	PeriodEnd     time.Time `json:"period_end"`
+	ActualSeconds int64     `json:"actual_seconds"`
+	Percent       float64   `json:"percent"`
+	Met           bool      `json:"met"`
+}
+
+func (g *Goal) OwnerID() uint {
+	return g.UserID
+}
+
+// IsMet reports whether the given total satisfies the goal
+func (g *Goal) IsMet(seconds int64) bool {
+	if g.Kind == GoalMax {
+		return seconds <= int64(g.TargetSeconds)
+	}
+	return seconds >= int64(g.TargetSeconds)
+}
+
+// Bounds returns the period containing t in loc, the end being exclusive.
+// Weeks start on Monday.
+func (p GoalPeriod) Bounds(t time.Time, loc *time.Location) (start, end time.Time) {
+	local := t.In(loc)
+	year, month, day := local.Date()
+
+	switch p {
+	case GoalWeekly:
+		offset := (int(local.Weekday()) + 6) % 7
+		start = time.Date(year, month, day-offset, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 0, 7)
+	case GoalMonthly:
+		start = time.Date(year, month, 1, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 1, 0)
+	default:
+		start = time.Date(year, month, day, 0, 0, 0, 0, loc)
+		return start, start.AddDate(0, 0, 1)
+	}
+}
@@ -20,37 +20,94 @@ type Activity struct {
 	ID          uint            `json:"id" gorm:"primaryKey"`
 	Date        time.Time       `json:"date" gorm:"not null;index"`
 	StartTime   time.Time       `json:"start_time" gorm:"not null"`
-	EndTime     time.Time       `json:"end_time" gorm:"not null"`
+	EndTime     *time.Time      `json:"end_time"`                 // nil while the timer is running
 	Duration    int             `json:"duration" gorm:"not null"` // in second
+	IsRunning   bool            `json:"is_running" gorm:"-"`
 	Description EncryptedString `json:"description" gorm:"type:text;not null"`
 	Notes       EncryptedString `json:"notes" gorm:"type:text"`
+	Mood        *int            `json:"mood" gorm:"check:chk_activities_mood,mood BETWEEN 1 AND 5"`       // 1 to 5, optional
+	Energy      *int            `json:"energy" gorm:"check:chk_activities_energy,energy BETWEEN 1 AND 5"` // 1 to 5, optional
 	CategoryID  uint            `json:"category_id" gorm:"not null"`
 	Category    Category        `json:"category" gorm:"foreignKey:CategoryID"`
-	UserID      uint            `json:"user_id" gorm:"not null"`
+	UserID      uint            `json:"user_id" gorm:"not null;index:idx_activities_running_user,unique,where:end_time IS NULL;index:idx_activities_external,unique,priority:1"`
 	User        User            `json:"user" gorm:"foreignKey:UserID"`
-	CreatedAt   time.Time       `json:"created_at"`
-	UpdatedAt   time.Time       `json:"updated_at"`
+	Tags        []Tag           `json:"tags" gorm:"many2many:activity_tags"`
+	Pending     bool            `json:"pending" gorm:"not null;default:false"` // generated from a recurring template, awaiting confirmation
+	// Activities imported from another time tracker remember where they came from, so importing again skips them
+	ExternalSource *string        `json:"external_source,omitempty" gorm:"type:varchar(20);index:idx_activities_external,unique,priority:2"`
+	ExternalID     *string        `json:"external_id,omitempty" gorm:"type:varchar(255);index:idx_activities_external,unique,priority:3"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `json:"deleted_at" gorm:"index"` // set while the activity is in the trash
 }
 
+// ErrActivityRunning is returned when a user tries to start a second timer
+var ErrActivityRunning = errors.New("another activity is already running")
+
+// ErrNegativeDuration is returned when an activity ends before it starts
+var ErrNegativeDuration = errors.New("duration cannot be negative")
+
 func (a *Activity) BeforeCreate(tx *gorm.DB) (err error) {
-	// set date and duration automatically by calculating start and end time
-	a.Date = a.StartTime.UTC().Truncate(24 * time.Hour)
-	a.Duration = int(a.EndTime.Sub(a.StartTime).Seconds())
+	return a.setDerivedFields(a.location(tx))
+}
+
+// Add BeforeUpdate hook to handle updates
+func (a *Activity) BeforeUpdate(tx *gorm.DB) (err error) {
+	return a.setDerivedFields(a.location(tx))
+}
+
+// AfterFind reports the elapsed time of running activities as their duration
+func (a *Activity) AfterFind(tx *gorm.DB) (err error) {
+	a.IsRunning = a.EndTime == nil
+	if a.IsRunning {
+		a.Duration = a.LiveDuration(time.Now())
+	}
+	return nil
+}
+
+// LiveDuration returns the duration in seconds, counting a running activity up to now
+func (a *Activity) LiveDuration(now time.Time) int {
+	end := now
+	if a.EndTime != nil {
+		end = *a.EndTime
+	}
+	if end.Before(a.StartTime) {
+		return 0
+	}
+	return int(end.Sub(a.StartTime).Seconds())
+}
 
+// location returns the time zone of the activity's owner, used to derive its date
+func (a *Activity) location(tx *gorm.DB) *time.Location {
+	if a.User.ID != 0 && a.User.ID == a.UserID {
+		return a.User.Location()
+	}
+
+	var user User
+	if err := tx.Session(&gorm.Session{NewDB: true}).Select("id", "timezone").First(&user, a.UserID).Error; err != nil {
+		return time.UTC
+	}
+	return user.Location()
+}
+
+// setDerivedFields sets date and duration automatically by calculating start and end time.
+// The date is the day the activity starts on in the owner's time zone.
+// A running activity has no end time yet, so its stored duration stays zero until it is stopped.
+func (a *Activity) setDerivedFields(loc *time.Location) error {
+	a.Date = LocalDate(a.StartTime, loc)
+	a.IsRunning = a.EndTime == nil
+	if a.IsRunning {
+		a.Duration = 0
+		return nil
+	}
+
+	a.Duration = int(a.EndTime.Sub(a.StartTime).Seconds())
 	if a.Duration < 0 {
-		return errors.New("duration cannot be negative")
+		return ErrNegativeDuration
 	}
 	return nil
 }
 
-// Add BeforeUpdate hook to handle updates
-func (a *Activity) BeforeUpdate(tx *gorm.DB) (err error) {
-    // set date and duration automatically by calculating start and end time
-    a.Date = a.StartTime.UTC().Truncate(24 * time.Hour)
-    a.Duration = int(a.EndTime.Sub(a.StartTime).Seconds())
-
-    if a.Duration < 0 {
-        return errors.New("duration cannot be negative")
-    }
-    return nil
+func (a *Activity) OwnerID() uint {
+	return a.UserID
 }
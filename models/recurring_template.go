@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+// RecurringTemplate is a routine that repeats, e.g. the gym every Monday, Wednesday and
+// Friday from 18:00 to 19:30. Its occurrences become pending activities for the user to
+// confirm. Times are wall clock times in the user's time zone, so they stay put across
+// daylight saving time changes.
+type RecurringTemplate struct {
+	ID          uint            `json:"id" gorm:"primaryKey"`
+	UserID      uint            `json:"user_id" gorm:"not null;index"`
+	CategoryID  uint            `json:"category_id" gorm:"not null"`
+	Category    Category        `json:"category" gorm:"foreignKey:CategoryID"`
+	Description EncryptedString `json:"description" gorm:"type:text;not null"`
+	Notes       EncryptedString `json:"notes" gorm:"type:text"`
+	RRule       string          `json:"rrule" gorm:"type:varchar(255);not null"`    // RFC 5545 recurrence rule, see package rrule
+	StartDate   time.Time       `json:"start_date" gorm:"type:date;not null"`       // day of the first occurrence
+	StartTime   string          `json:"start_time" gorm:"type:varchar(5);not null"` // HH:MM
+	EndTime     string          `json:"end_time" gorm:"type:varchar(5);not null"`   // HH:MM, on the next day when not after the start time
+	Paused      bool            `json:"paused" gorm:"not null;default:false"`       // paused templates generate nothing
+	// Occurrences starting before GeneratedUntil have been turned into activities
+	GeneratedUntil *time.Time `json:"generated_until"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// RecurringTemplateRequest creates or replaces a recurring template
+type RecurringTemplateRequest struct {
+	CategoryID  uint   `json:"category_id" binding:"required"`
+	Description string `json:"description" binding:"required"`
+	Notes       string `json:"notes"`
+	RRule       string `json:"rrule" binding:"required"`      // e.g. FREQ=WEEKLY;BYDAY=MO,WE,FR
+	StartDate   string `json:"start_date" binding:"required"` // YYYY-MM-DD
+	StartTime   string `json:"start_time" binding:"required"` // HH:MM
+	EndTime     string `json:"end_time" binding:"required"`   // HH:MM
+	Paused      bool   `json:"paused"`
+}
+
+func (t *RecurringTemplate) OwnerID() uint {
+	return t.UserID
+}
+
+// Occurrence returns the start and end of the occurrence starting on the given day in loc
+func (t *RecurringTemplate) Occurrence(day time.Time, loc *time.Location) (start, end time.Time) {
+	year, month, date := day.Date()
+	startClock, _ := time.Parse("15:04", t.StartTime)
+	endClock, _ := time.Parse("15:04", t.EndTime)
+	start = time.Date(year, month, date, startClock.Hour(), startClock.Minute(), 0, 0, loc)
+	end = time.Date(year, month, date, endClock.Hour(), endClock.Minute(), 0, 0, loc)
+	if !end.After(start) {
+		end = time.Date(year, month, date+1, endClock.Hour(), endClock.Minute(), 0, 0, loc)
+	}
+	return start, end
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// ActivityRevision records one change to an activity. It holds the values the
+// activity had before the change, so reverting to a revision brings them back.
+// Description and notes are stored encrypted like on the activity itself.
+type ActivityRevision struct {
+	ID            uint            `json:"id" gorm:"primaryKey"`
+	ActivityID    uint            `json:"activity_id" gorm:"not null;uniqueIndex:idx_activity_revisions_number"`
+	Revision      int             `json:"revision" gorm:"not null;uniqueIndex:idx_activity_revisions_number"` // counts up from 1 per activity
+	ChangedByID   uint            `json:"changed_by_id" gorm:"not null"`
+	ChangedBy     User            `json:"-" gorm:"foreignKey:ChangedByID"`       // only for the foreign key, never loaded
+	ChangedByName string          `json:"changed_by_name" gorm:"->;-:migration"` // read from users when listing the history
+	ChangedFields []string        `json:"changed_fields" gorm:"serializer:json;not null"`
+	StartTime     time.Time       `json:"start_time" gorm:"not null"`
+	EndTime       *time.Time      `json:"end_time"`
+	Description   EncryptedString `json:"description" gorm:"type:text;not null"`
+	Notes         EncryptedString `json:"notes" gorm:"type:text"`
+	Mood          *int            `json:"mood"`
+	Energy        *int            `json:"energy"`
+	CategoryID    uint            `json:"category_id" gorm:"not null"`
+	Tags          []string        `json:"tags" gorm:"serializer:json"` // tag names
+	CreatedAt     time.Time       `json:"created_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+)
+
+// Tag is a free-form label a user attaches to their activities
+type Tag struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_tags_user_name"`
+	Name      string    `json:"name" gorm:"type:varchar(64);not null;uniqueIndex:idx_tags_user_name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type TagRequest struct {
+	Name string `json:"name" binding:"required,max=64"`
+}
+
+type MergeTagRequest struct {
+	TargetID uint `json:"target_id" binding:"required"`
+}
+
+func (t *Tag) OwnerID() uint {
+	return t.UserID
+}
@@ -19,6 +19,7 @@ type User struct {
 	Picture     string     `json:"picture"`
 	GoogleID    string     `json:"google_id" gorm:"unique;not null"`
 	Role        Role       `json:"role" gorm:"type:varchar(10);default:user"`
+	Timezone    string     `json:"timezone" gorm:"type:varchar(64);not null;default:UTC"` // IANA name, e.g. Asia/Jakarta
 	Activities  []Activity `json:"activities,omitempty" gorm:"foreignKey:UserID"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
@@ -28,3 +29,30 @@ type User struct {
 type ChangeRoleRequest struct {
 	Role Role `json:"role" binding:"required"`
 }
+
+type ChangeTimezoneRequest struct {
+	Timezone string `json:"timezone" binding:"required"`
+}
+
+// Location returns the user's time zone, falling back to UTC when it is unset or unknown
+func (u User) Location() *time.Location {
+	if u.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(u.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// LocalDate returns the calendar day of t in loc, stored as midnight UTC
+func LocalDate(t time.Time, loc *time.Location) time.Time {
+	year, month, day := t.In(loc).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// Owned is implemented by resources that belong to a single user
+type Owned interface {
+	OwnerID() uint
+}
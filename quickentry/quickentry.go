@@ -0,0 +1,396 @@
+// Package quickentry reads the free text of a quick entry, such as "gym 7-8:30am",
+// "slept 23:00-06:30" or "lunch 45m yesterday", into the times of an activity.
+//
+// Parse finds the time range, duration, clock time and day in the text and keeps the
+// rest as the description. Resolve turns them into candidate start and end times, most
+// likely first. Clock times without am or pm are read both ways and the reading giving
+// the shortest activity wins; times without a day fall on the latest day they have
+// started by.
+package quickentry
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// fallbackDuration is the duration of an entry without one when there is no usual duration
+const fallbackDuration = time.Hour
+
+var (
+	dateRe     = regexp.MustCompile(`(?i)\b(?:on\s+)?(\d{4}-\d{2}-\d{2})\b`)
+	dayRe      = regexp.MustCompile(`(?i)\b(?:(today|tonight|yesterday|last\s+night|this\s+(?:morning|afternoon|evening))|(?:(last|on)\s+)?(monday|tuesday|wednesday|thursday|friday|saturday|sunday))\b`)
+	rangeRe    = regexp.MustCompile(`(?i)\b(?:from\s+)?(\d{1,2})(?::(\d{2}))?\s*(am|pm)?\s*(?:-|–|to|until|till)\s*(\d{1,2})(?::(\d{2}))?\s*(am|pm)?\b`)
+	durationRe = regexp.MustCompile(`(?i)\b(?:for\s+)?(?:(\d+(?:\.\d+)?)\s*(?:hours|hour|hrs|hr|h)(?:\s*(\d{1,2})\s*(?:minutes|minute|mins|min|m)?)?|(\d+)\s*(?:minutes|minute|mins|min|m))\b`)
+	clockRe    = regexp.MustCompile(`(?i)(\bat\s+|@\s*)?\b(\d{1,2})(?::(\d{2}))?\s*(am|pm)?\b`)
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// fillers are the words left at the ends of a description once the times are taken out
+var fillers = map[string]bool{"from": true, "to": true, "at": true, "on": true, "for": true, "until": true, "till": true}
+
+// clock is a time of day as written
+type clock struct {
+	hour, minute int
+	meridiem     string // "am", "pm" or empty when not written
+	fixed        bool   // the hour cannot be am or pm, e.g. 23:00 or 06:30
+}
+
+// parseClock reads the hour, minutes and am/pm of a clock time
+func parseClock(hour, minute, meridiem string) (clock, bool) {
+	c := clock{meridiem: strings.ToLower(meridiem)}
+	c.hour, _ = strconv.Atoi(hour)
+	if minute != "" {
+		c.minute, _ = strconv.Atoi(minute)
+	}
+	if c.minute > 59 || c.hour > 24 || c.meridiem != "" && (c.hour == 0 || c.hour > 12) {
+		return c, false
+	}
+	c.fixed = c.hour == 0 || c.hour > 12 || len(hour) == 2 && hour[0] == '0'
+	return c, true
+}
+
+// readings returns the times of day, in minutes after midnight, the clock can stand for
+func (c clock) readings() []int {
+	h := c.hour % 12
+	switch {
+	case c.meridiem == "am":
+		return []int{h*60 + c.minute}
+	case c.meridiem == "pm":
+		return []int{(h+12)*60 + c.minute}
+	case c.fixed:
+		return []int{c.hour%24*60 + c.minute}
+	}
+	return []int{h*60 + c.minute, (h+12)*60 + c.minute}
+}
+
+// Entry is a parsed quick entry
+type Entry struct {
+	Description string // the text without the times and the day
+
+	start, end *clock
+	duration   time.Duration
+	day        func(today time.Time) time.Time // nil when the text names no day
+	meridiem   string                          // am or pm implied by e.g. "this morning" or "tonight"
+}
+
+// Defaults are what is usual for the activity, used for what the text leaves out
+type Defaults struct {
+	Start    *int          // usual start, in minutes after midnight
+	Duration time.Duration // usual duration, 0 when unknown
+}
+
+// Candidate is one reading of the times of an entry
+type Candidate struct {
+	Start      time.Time
+	End        time.Time
+	Confidence float64  // 0 to 1
+	Assumed    []string // what was not in the text
+}
+
+// Parse reads the times and the day of a quick entry
+func Parse(text string) Entry {
+	var entry Entry
+	take := func(re *regexp.Regexp) []string {
+		loc := re.FindStringSubmatchIndex(text)
+		if loc == nil {
+			return nil
+		}
+		match := make([]string, len(loc)/2)
+		for i := range match {
+			if loc[2*i] >= 0 {
+				match[i] = text[loc[2*i]:loc[2*i+1]]
+			}
+		}
+		text = text[:loc[0]] + " " + text[loc[1]:]
+		return match
+	}
+
+	if m := take(dateRe); m != nil {
+		if date, err := time.Parse("2006-01-02", m[1]); err == nil {
+			entry.day = func(today time.Time) time.Time {
+				return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, today.Location())
+			}
+		}
+	}
+	if m := take(dayRe); m != nil && entry.day == nil {
+		entry.day, entry.meridiem = dayOf(strings.Join(strings.Fields(strings.ToLower(m[1])), " "), strings.ToLower(m[2]), strings.ToLower(m[3]))
+	}
+
+	if m := take(rangeRe); m != nil {
+		start, okStart := parseClock(m[1], m[2], m[3])
+		end, okEnd := parseClock(m[4], m[5], m[6])
+		if okStart && okEnd {
+			entry.start, entry.end = &start, &end
+		}
+	}
+
+	if m := take(durationRe); m != nil {
+		if m[3] != "" {
+			minutes, _ := strconv.Atoi(m[3])
+			entry.duration = time.Duration(minutes) * time.Minute
+		} else {
+			hours, _ := strconv.ParseFloat(m[1], 64)
+			minutes, _ := strconv.Atoi(m[2])
+			entry.duration = time.Duration(hours*float64(time.Hour)) + time.Duration(minutes)*time.Minute
+		}
+		if entry.duration > 24*time.Hour {
+			entry.duration = 0
+		}
+	}
+
+	// A lone number is only a clock time after "at" or "@", or with minutes or am/pm
+	if entry.start == nil {
+		for _, loc := range clockRe.FindAllStringSubmatchIndex(text, -1) {
+			group := func(i int) string {
+				if loc[2*i] < 0 {
+					return ""
+				}
+				return text[loc[2*i]:loc[2*i+1]]
+			}
+			if group(1) == "" && group(3) == "" && group(4) == "" {
+				continue
+			}
+			if start, ok := parseClock(group(2), group(3), group(4)); ok {
+				entry.start = &start
+				text = text[:loc[0]] + " " + text[loc[1]:]
+				break
+			}
+		}
+	}
+
+	words := strings.Fields(text)
+	for len(words) > 0 && isFiller(words[0]) {
+		words = words[1:]
+	}
+	for len(words) > 0 && isFiller(words[len(words)-1]) {
+		words = words[:len(words)-1]
+	}
+	entry.Description = strings.Join(words, " ")
+	return entry
+}
+
+// isFiller reports whether a word is punctuation or a filler word
+func isFiller(word string) bool {
+	word = strings.ToLower(strings.Trim(word, ",.;:-–@"))
+	return word == "" || fillers[word]
+}
+
+// dayOf returns the day named by a day word, or a weekday with an optional "last" or
+// "on", and the am or pm the word implies
+func dayOf(word, qualifier, weekday string) (func(today time.Time) time.Time, string) {
+	switch word {
+	case "today":
+		return func(today time.Time) time.Time { return today }, ""
+	case "this morning":
+		return func(today time.Time) time.Time { return today }, "am"
+	case "tonight", "this afternoon", "this evening":
+		return func(today time.Time) time.Time { return today }, "pm"
+	case "yesterday":
+		return func(today time.Time) time.Time { return today.AddDate(0, 0, -1) }, ""
+	case "last night":
+		return func(today time.Time) time.Time { return today.AddDate(0, 0, -1) }, "pm"
+	}
+	target := weekdays[weekday]
+	return func(today time.Time) time.Time {
+		days := (int(today.Weekday()) - int(target) + 7) % 7
+		if days == 0 && qualifier == "last" {
+			days = 7
+		}
+		return today.AddDate(0, 0, -days)
+	}, ""
+}
+
+// reading is a start time of day and a duration of an entry
+type reading struct {
+	start      int // minutes after midnight
+	duration   time.Duration
+	until      int // for a range, the end in minutes after midnight of the start day
+	confidence float64
+	assumed    []string
+}
+
+// clockTime returns the time of day, in minutes after midnight, on a day. The minutes are
+// counted on the clock, so on the day of a DST change 7:00 is still 7:00.
+func clockTime(day time.Time, minutes int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, minutes, 0, 0, day.Location())
+}
+
+// end returns the end of the reading starting on day. A range ends at its clock time,
+// a duration is counted in elapsed time.
+func (r reading) end(day time.Time) time.Time {
+	if r.until > 0 {
+		return clockTime(day, r.until)
+	}
+	return clockTime(day, r.start).Add(r.duration)
+}
+
+// Resolve returns the candidate times of the entry, most likely first. There is always
+// at least one candidate.
+func (e Entry) Resolve(now time.Time, loc *time.Location, defaults Defaults) []Candidate {
+	now = now.In(loc)
+	year, month, day := now.Date()
+	today := time.Date(year, month, day, 0, 0, 0, 0, loc)
+
+	duration, durationAssumed := e.duration, []string(nil)
+	if duration == 0 {
+		duration, durationAssumed = defaults.Duration, []string{"usual duration"}
+		if duration == 0 {
+			duration, durationAssumed = fallbackDuration, []string{"duration of 1 hour"}
+		}
+	}
+
+	// Without a clock time the entry ends now, or at the usual time on the day it names
+	if e.start == nil {
+		confidence := 0.85
+		if e.duration == 0 {
+			confidence = 0.4
+		}
+		if e.day == nil {
+			return []Candidate{{
+				Start:      now.Add(-duration),
+				End:        now,
+				Confidence: confidence,
+				Assumed:    append(durationAssumed, "ends now"),
+			}}
+		}
+		start := e.day(today)
+		if defaults.Start != nil {
+			start = clockTime(start, *defaults.Start)
+			return []Candidate{{
+				Start:      start,
+				End:        start.Add(duration),
+				Confidence: confidence * 0.6,
+				Assumed:    append(durationAssumed, "usual start time"),
+			}}
+		}
+		end := time.Date(start.Year(), start.Month(), start.Day(), now.Hour(), now.Minute(), 0, 0, loc)
+		return []Candidate{{
+			Start:      end.Add(-duration),
+			End:        end,
+			Confidence: confidence * 0.5,
+			Assumed:    append(durationAssumed, "ends at the current time of day"),
+		}}
+	}
+
+	readings := e.readings(duration, durationAssumed, defaults)
+
+	var days []time.Time
+	if e.day != nil {
+		days = []time.Time{e.day(today)}
+	} else {
+		days = []time.Time{today, today.AddDate(0, 0, -1)}
+	}
+
+	var candidates []Candidate
+	for _, r := range readings {
+		latest := true
+		for _, day := range days {
+			start := clockTime(day, r.start)
+			if e.day == nil && start.After(now) {
+				continue
+			}
+			confidence := r.confidence
+			if !latest {
+				confidence *= 0.6
+			}
+			latest = false
+			end := r.end(day)
+			if end.After(now) {
+				confidence *= 0.8
+			}
+			candidates = append(candidates, Candidate{Start: start, End: end, Confidence: confidence, Assumed: r.assumed})
+		}
+	}
+	// An entry for later today is kept when nothing has started yet
+	if len(candidates) == 0 {
+		for _, r := range readings {
+			candidates = append(candidates, Candidate{Start: clockTime(today, r.start), End: r.end(today), Confidence: r.confidence * 0.5, Assumed: r.assumed})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Confidence != candidates[j].Confidence {
+			return candidates[i].Confidence > candidates[j].Confidence
+		}
+		return candidates[i].Start.After(candidates[j].Start)
+	})
+	return candidates
+}
+
+// readings returns the possible start times of day and durations of an entry with a clock
+// time. A range keeps only its shortest readings, so "11-1pm" is 11am to 1pm.
+func (e Entry) readings(duration time.Duration, durationAssumed []string, defaults Defaults) []reading {
+	var readings []reading
+	starts := e.start.readings()
+	ambiguous := len(starts) > 1
+
+	if e.end != nil {
+		ends := e.end.readings()
+		shortest := 24 * 60
+		for _, start := range starts {
+			for _, end := range ends {
+				minutes := (end - start + 24*60) % (24 * 60)
+				if minutes == 0 {
+					continue
+				}
+				if minutes < shortest {
+					shortest, readings = minutes, nil
+				}
+				if minutes == shortest {
+					readings = append(readings, reading{start: start, duration: time.Duration(minutes) * time.Minute, until: start + minutes, confidence: 0.95})
+				}
+			}
+		}
+		if len(readings) == 0 {
+			// A range starting and ending at the same time lasts a day
+			readings = []reading{{start: starts[0], duration: 24 * time.Hour, until: starts[0] + 24*60, confidence: 0.5}}
+		}
+		ambiguous = len(readings) > 1
+	} else {
+		confidence := 0.9
+		var assumed []string
+		if e.duration == 0 {
+			confidence, assumed = 0.6, durationAssumed
+		}
+		for _, start := range starts {
+			readings = append(readings, reading{start: start, duration: duration, confidence: confidence, assumed: assumed})
+		}
+	}
+
+	if !ambiguous {
+		return readings
+	}
+	for i := range readings {
+		r := &readings[i]
+		r.confidence *= 0.75
+		r.assumed = append([]string{"am or pm"}, r.assumed...)
+		if pm := r.start >= 12*60; e.meridiem != "" && pm != (e.meridiem == "pm") {
+			r.confidence *= 0.5
+		}
+		if defaults.Start != nil && clockDistance(r.start, *defaults.Start) > 3*60 {
+			r.confidence *= 0.8
+		}
+	}
+	return readings
+}
+
+// clockDistance returns the minutes between two times of day, across midnight if shorter
+func clockDistance(a, b int) int {
+	d := (a - b + 24*60) % (24 * 60)
+	if d > 12*60 {
+		d = 24*60 - d
+	}
+	return d
+}
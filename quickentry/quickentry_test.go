@@ -0,0 +1,191 @@
+package quickentry
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolve(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wednesday := time.Date(2024, 3, 20, 10, 0, 0, 0, loc)
+	noon := 12 * 60
+
+	tests := []struct {
+		text        string
+		now         time.Time // defaults to wednesday
+		defaults    Defaults
+		description string
+		start, end  string // of the best candidate, as 2006-01-02 15:04 -0700
+		assumed     string // of the best candidate, joined with commas
+	}{
+		{
+			text: "gym 7-8:30am", description: "gym",
+			start: "2024-03-20 07:00 +0100", end: "2024-03-20 08:30 +0100",
+		},
+		{
+			text: "slept 23:00-06:30", description: "slept",
+			start: "2024-03-19 23:00 +0100", end: "2024-03-20 06:30 +0100",
+		},
+		{
+			text: "lunch 45m yesterday", description: "lunch",
+			start: "2024-03-19 09:15 +0100", end: "2024-03-19 10:00 +0100",
+			assumed: "ends at the current time of day",
+		},
+		{
+			text: "lunch 45m yesterday", defaults: Defaults{Start: &noon}, description: "lunch",
+			start: "2024-03-19 12:00 +0100", end: "2024-03-19 12:45 +0100",
+			assumed: "usual start time",
+		},
+		{
+			text: "11-1pm meeting", description: "meeting",
+			start: "2024-03-19 11:00 +0100", end: "2024-03-19 13:00 +0100",
+		},
+		{
+			text: "read for 1h30", description: "read",
+			start: "2024-03-20 08:30 +0100", end: "2024-03-20 10:00 +0100",
+			assumed: "ends now",
+		},
+		{
+			text: "gym", description: "gym",
+			start: "2024-03-20 09:00 +0100", end: "2024-03-20 10:00 +0100",
+			assumed: "duration of 1 hour,ends now",
+		},
+		{
+			text: "call at 3pm", description: "call",
+			start: "2024-03-19 15:00 +0100", end: "2024-03-19 16:00 +0100",
+			assumed: "duration of 1 hour",
+		},
+		{
+			text: "call at 3pm", defaults: Defaults{Duration: 30 * time.Minute}, description: "call",
+			start: "2024-03-19 15:00 +0100", end: "2024-03-19 15:30 +0100",
+			assumed: "usual duration",
+		},
+		{
+			text: "tv last night 9-11", description: "tv",
+			start: "2024-03-19 21:00 +0100", end: "2024-03-19 23:00 +0100",
+			assumed: "am or pm",
+		},
+		{
+			text: "run on monday 6-7am", description: "run",
+			start: "2024-03-18 06:00 +0100", end: "2024-03-18 07:00 +0100",
+		},
+		{
+			text: "run last wednesday 6-7am", description: "run",
+			start: "2024-03-13 06:00 +0100", end: "2024-03-13 07:00 +0100",
+		},
+		{
+			text: "run 2024-05-01 6-7am", description: "run",
+			start: "2024-05-01 06:00 +0200", end: "2024-05-01 07:00 +0200",
+		},
+		{
+			text: "coffee with Anna @ 8:15 for 20 min", description: "coffee with Anna",
+			start: "2024-03-20 08:15 +0100", end: "2024-03-20 08:35 +0100",
+			assumed: "am or pm",
+		},
+		{
+			text: "gym 7-8:30am", now: time.Date(2024, 3, 31, 12, 0, 0, 0, loc), description: "gym",
+			start: "2024-03-31 07:00 +0200", end: "2024-03-31 08:30 +0200",
+		},
+		{
+			text: "slept 23:00-07:00", now: time.Date(2024, 3, 31, 12, 0, 0, 0, loc), description: "slept",
+			start: "2024-03-30 23:00 +0100", end: "2024-03-31 07:00 +0200",
+		},
+		{
+			text: "slept 23:00-07:00", now: time.Date(2024, 10, 27, 12, 0, 0, 0, loc), description: "slept",
+			start: "2024-10-26 23:00 +0200", end: "2024-10-27 07:00 +0100",
+		},
+		{
+			text: "nap at 14:00 for 8h", now: time.Date(2024, 3, 31, 23, 0, 0, 0, loc), description: "nap",
+			start: "2024-03-31 14:00 +0200", end: "2024-03-31 22:00 +0200",
+		},
+		{
+			text: "lunch yesterday", now: time.Date(2024, 4, 1, 10, 0, 0, 0, loc), defaults: Defaults{Start: &noon, Duration: time.Hour}, description: "lunch",
+			start: "2024-03-31 12:00 +0200", end: "2024-03-31 13:00 +0200",
+			assumed: "usual duration,usual start time",
+		},
+	}
+
+	const layout = "2006-01-02 15:04 -0700"
+	for _, tt := range tests {
+		now := tt.now
+		if now.IsZero() {
+			now = wednesday
+		}
+		entry := Parse(tt.text)
+		if entry.Description != tt.description {
+			t.Errorf("Parse(%q).Description = %q, want %q", tt.text, entry.Description, tt.description)
+		}
+		candidates := entry.Resolve(now, loc, tt.defaults)
+		if len(candidates) == 0 {
+			t.Errorf("%q: no candidates", tt.text)
+			continue
+		}
+		best := candidates[0]
+		if got := best.Start.Format(layout); got != tt.start {
+			t.Errorf("%q at %v: start %s, want %s", tt.text, now, got, tt.start)
+		}
+		if got := best.End.Format(layout); got != tt.end {
+			t.Errorf("%q at %v: end %s, want %s", tt.text, now, got, tt.end)
+		}
+		if got := strings.Join(best.Assumed, ","); got != tt.assumed {
+			t.Errorf("%q: assumed %q, want %q", tt.text, got, tt.assumed)
+		}
+		for i := 1; i < len(candidates); i++ {
+			if candidates[i].Confidence > candidates[i-1].Confidence {
+				t.Errorf("%q: candidates are not ordered by confidence", tt.text)
+			}
+		}
+		if best.Confidence <= 0 || best.Confidence > 1 {
+			t.Errorf("%q: confidence %v is not in (0, 1]", tt.text, best.Confidence)
+		}
+	}
+}
+
+func TestResolveAmbiguous(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2024, 3, 20, 22, 0, 0, 0, loc)
+
+	// Both readings of "sleep 11-7" last 8 hours, so both are offered
+	candidates := Parse("sleep 11-7").Resolve(now, loc, Defaults{})
+	var starts []string
+	for _, candidate := range candidates {
+		starts = append(starts, candidate.Start.Format("01-02 15:04"))
+	}
+	for _, want := range []string{"03-19 23:00", "03-20 11:00"} {
+		if !strings.Contains(strings.Join(starts, ","), want) {
+			t.Errorf("sleep 11-7: candidates %v, want one starting %s", starts, want)
+		}
+	}
+
+	// A usual start time close to one reading favours it
+	usual := 23 * 60
+	candidates = Parse("sleep 11-7").Resolve(now, loc, Defaults{Start: &usual})
+	if got := candidates[0].Start.Format("01-02 15:04"); got != "03-19 23:00" {
+		t.Errorf("sleep 11-7 with a usual start of 23:00: best start %s, want 03-19 23:00", got)
+	}
+}
+
+func TestParseDescription(t *testing.T) {
+	tests := map[string]string{
+		"gym 7-8:30am":                "gym",
+		"from 9 to 17 work":           "work",
+		"deep work until 5pm":         "deep work",
+		"at 7 breakfast":              "breakfast",
+		"read 20 pages for 30 mins":   "read 20 pages",
+		"walk with the dog 2h today":  "walk with the dog",
+		"dinner this evening at 7:30": "dinner",
+		"":                            "",
+	}
+	for text, want := range tests {
+		if got := Parse(text).Description; got != want {
+			t.Errorf("Parse(%q).Description = %q, want %q", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,399 @@
+// Package rrule reads, writes and expands a subset of iCalendar (RFC 5545) recurrence rules.
+//
+// Supported parts are FREQ (DAILY, WEEKLY, MONTHLY or YEARLY), INTERVAL, COUNT, UNTIL,
+// BYDAY, BYMONTHDAY, BYMONTH and WKST. Weeks always start on Monday. In a YEARLY rule,
+// BYDAY applies within the months of BYMONTH, so it needs BYMONTH.
+package rrule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ of a rule
+type Frequency string
+
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+	Yearly  Frequency = "YEARLY"
+)
+
+// maxPeriods caps how many days, weeks, months or years are scanned for occurrences
+const maxPeriods = 100000
+
+// untilFormat is the UTC form of UNTIL values
+const untilFormat = "20060102T150405Z"
+
+// WeekdayNum is a BYDAY value, e.g. MO, or -1FR for the last Friday of the month
+type WeekdayNum struct {
+	Weekday time.Weekday
+	N       int // 0 for every such weekday of the period
+}
+
+// Rule is a recurrence rule
+type Rule struct {
+	Freq       Frequency
+	Interval   int       // at least 1
+	Count      int       // 0 when unlimited
+	Until      time.Time // zero when unlimited, inclusive
+	ByDay      []WeekdayNum
+	ByMonthDay []int // 1 to 31, or -1 to -31 counting from the end of the month
+	ByMonth    []time.Month
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// Parse reads a rule, with or without the RRULE: prefix. UNTIL values without a time
+// zone are read in loc, and an UNTIL date includes that whole day.
+func Parse(value string, loc *time.Location) (Rule, error) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "RRULE:")
+	rule := Rule{Interval: 1}
+	if value == "" {
+		return rule, errors.New("empty recurrence rule")
+	}
+
+	for _, part := range strings.Split(value, ";") {
+		name, arg, ok := strings.Cut(part, "=")
+		if !ok || arg == "" {
+			return rule, fmt.Errorf("invalid rule part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			rule.Freq = Frequency(strings.ToUpper(arg))
+			switch rule.Freq {
+			case Daily, Weekly, Monthly, Yearly:
+			default:
+				err = fmt.Errorf("unsupported frequency %q", arg)
+			}
+		case "INTERVAL":
+			rule.Interval, err = parsePositive(arg)
+		case "COUNT":
+			rule.Count, err = parsePositive(arg)
+		case "UNTIL":
+			rule.Until, err = parseUntil(arg, loc)
+		case "BYDAY":
+			for _, day := range strings.Split(arg, ",") {
+				weekday, parseErr := parseWeekdayNum(day)
+				if parseErr != nil {
+					err = parseErr
+					break
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(arg, ",") {
+				number, parseErr := strconv.Atoi(day)
+				if parseErr != nil || number == 0 || number < -31 || number > 31 {
+					err = fmt.Errorf("invalid month day %q", day)
+					break
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, number)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(arg, ",") {
+				number, parseErr := strconv.Atoi(month)
+				if parseErr != nil || number < 1 || number > 12 {
+					err = fmt.Errorf("invalid month %q", month)
+					break
+				}
+				rule.ByMonth = append(rule.ByMonth, time.Month(number))
+			}
+		case "WKST":
+			if strings.ToUpper(arg) != "MO" {
+				err = errors.New("only WKST=MO is supported")
+			}
+		default:
+			err = fmt.Errorf("unsupported rule part %q", name)
+		}
+		if err != nil {
+			return rule, err
+		}
+	}
+
+	if rule.Freq == "" {
+		return rule, errors.New("FREQ is required")
+	}
+	if rule.Count > 0 && !rule.Until.IsZero() {
+		return rule, errors.New("COUNT and UNTIL cannot be used together")
+	}
+	for _, day := range rule.ByDay {
+		if day.N != 0 && rule.Freq != Monthly && rule.Freq != Yearly {
+			return rule, errors.New("numbered BYDAY values need a MONTHLY or YEARLY frequency")
+		}
+	}
+	if rule.Freq == Yearly && len(rule.ByDay) > 0 && len(rule.ByMonth) == 0 {
+		return rule, errors.New("BYDAY in a YEARLY rule needs BYMONTH")
+	}
+	if rule.Freq == Weekly && len(rule.ByMonthDay) > 0 {
+		return rule, errors.New("BYMONTHDAY cannot be used with a WEEKLY frequency")
+	}
+	return rule, nil
+}
+
+func parsePositive(value string) (int, error) {
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 {
+		return 0, fmt.Errorf("%q is not a positive number", value)
+	}
+	return number, nil
+}
+
+func parseUntil(value string, loc *time.Location) (time.Time, error) {
+	if until, err := time.Parse(untilFormat, value); err == nil {
+		return until, nil
+	}
+	if until, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return until, nil
+	}
+	if date, err := time.ParseInLocation("20060102", value, loc); err == nil {
+		return date.AddDate(0, 0, 1).Add(-time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %q", value)
+}
+
+func parseWeekdayNum(value string) (WeekdayNum, error) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if len(value) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid weekday %q", value)
+	}
+	weekday, ok := weekdayNames[value[len(value)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid weekday %q", value)
+	}
+	day := WeekdayNum{Weekday: weekday}
+	if prefix := value[:len(value)-2]; prefix != "" {
+		n, err := strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return WeekdayNum{}, fmt.Errorf("invalid weekday %q", value)
+		}
+		day.N = n
+	}
+	return day, nil
+}
+
+// String returns the rule in its RFC 5545 form, without the RRULE: prefix
+func (r Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(untilFormat))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, day := range r.ByDay {
+			days[i] = strings.ToUpper(day.Weekday.String()[:2])
+			if day.N != 0 {
+				days[i] = strconv.Itoa(day.N) + days[i]
+			}
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, month := range r.ByMonth {
+			months[i] = strconv.Itoa(int(month))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	return strings.Join(parts, ";")
+}
+
+// Between returns the occurrences of the rule starting at or after from and before to.
+// start is the first occurrence (DTSTART). Occurrences keep its wall clock time in its
+// location, so they stay at the same local time across daylight saving time changes.
+func (r Rule) Between(start, from, to time.Time) []time.Time {
+	var occurrences []time.Time
+	count := 0
+	r.each(start, func(occurrence time.Time) bool {
+		if !occurrence.Before(to) {
+			return false
+		}
+		if !r.Until.IsZero() && occurrence.After(r.Until) {
+			return false
+		}
+		count++
+		if r.Count > 0 && count > r.Count {
+			return false
+		}
+		if !occurrence.Before(from) {
+			occurrences = append(occurrences, occurrence)
+		}
+		return true
+	})
+	return occurrences
+}
+
+// each calls yield with the occurrences from start on in order, until it returns false
+func (r Rule) each(start time.Time, yield func(time.Time) bool) {
+	interval := r.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	loc := start.Location()
+	year, month, day := start.Date()
+	first := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+	for period := 0; period < maxPeriods; period++ {
+		step := period * interval
+		var days []time.Time
+		switch r.Freq {
+		case Daily:
+			days = r.filter([]time.Time{first.AddDate(0, 0, step)})
+		case Weekly:
+			monday := first.AddDate(0, 0, -((int(first.Weekday())+6)%7)+7*step)
+			days = r.weekDays(monday, start.Weekday())
+		case Monthly:
+			monthStart := time.Date(year, month+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+			days = r.monthDays(monthStart, day)
+		case Yearly:
+			months := r.ByMonth
+			if len(months) == 0 {
+				months = []time.Month{month}
+			}
+			for _, m := range months {
+				days = append(days, r.monthDays(time.Date(year+step, m, 1, 0, 0, 0, 0, time.UTC), day)...)
+			}
+			sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+		default:
+			return
+		}
+
+		for _, d := range days {
+			if d.Before(first) {
+				continue
+			}
+			occurrence := time.Date(d.Year(), d.Month(), d.Day(), start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), loc)
+			if !yield(occurrence) {
+				return
+			}
+		}
+	}
+}
+
+// filter keeps the days matching BYMONTH, BYMONTHDAY and unnumbered BYDAY
+func (r Rule) filter(days []time.Time) []time.Time {
+	kept := days[:0]
+	for _, d := range days {
+		if len(r.ByMonth) > 0 && !containsMonth(r.ByMonth, d.Month()) {
+			continue
+		}
+		if len(r.ByMonthDay) > 0 && !matchesMonthDay(r.ByMonthDay, d) {
+			continue
+		}
+		if len(r.ByDay) > 0 && !matchesWeekday(r.ByDay, d.Weekday()) {
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
+
+// weekDays returns the days of the week starting on monday matching BYDAY, or the
+// weekday of the first occurrence
+func (r Rule) weekDays(monday time.Time, weekday time.Weekday) []time.Time {
+	var days []time.Time
+	for i := 0; i < 7; i++ {
+		d := monday.AddDate(0, 0, i)
+		if len(r.ByDay) > 0 && !matchesWeekday(r.ByDay, d.Weekday()) || len(r.ByDay) == 0 && d.Weekday() != weekday {
+			continue
+		}
+		if len(r.ByMonth) > 0 && !containsMonth(r.ByMonth, d.Month()) {
+			continue
+		}
+		days = append(days, d)
+	}
+	return days
+}
+
+// monthDays returns the days of the month matching BYMONTHDAY and BYDAY, or the day
+// of month of the first occurrence. Months without that day are left out.
+func (r Rule) monthDays(monthStart time.Time, day int) []time.Time {
+	if len(r.ByMonth) > 0 && !containsMonth(r.ByMonth, monthStart.Month()) {
+		return nil
+	}
+	length := monthStart.AddDate(0, 1, -1).Day()
+
+	var days []time.Time
+	for i := 1; i <= length; i++ {
+		d := monthStart.AddDate(0, 0, i-1)
+		switch {
+		case len(r.ByMonthDay) > 0:
+			if !matchesMonthDay(r.ByMonthDay, d) || len(r.ByDay) > 0 && !matchesWeekday(r.ByDay, d.Weekday()) {
+				continue
+			}
+		case len(r.ByDay) > 0:
+			if !matchesNumberedWeekday(r.ByDay, d, length) {
+				continue
+			}
+		default:
+			if i != day {
+				continue
+			}
+		}
+		days = append(days, d)
+	}
+	return days
+}
+
+func containsMonth(months []time.Month, month time.Month) bool {
+	for _, m := range months {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesMonthDay(monthDays []int, d time.Time) bool {
+	length := time.Date(d.Year(), d.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, monthDay := range monthDays {
+		if monthDay == d.Day() || monthDay < 0 && length+monthDay+1 == d.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesWeekday(days []WeekdayNum, weekday time.Weekday) bool {
+	for _, day := range days {
+		if day.Weekday == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesNumberedWeekday reports whether d matches BYDAY within its month, where 2MO is
+// the second Monday and -1MO the last one
+func matchesNumberedWeekday(days []WeekdayNum, d time.Time, monthLength int) bool {
+	fromStart := (d.Day()-1)/7 + 1
+	fromEnd := -((monthLength-d.Day())/7 + 1)
+	for _, day := range days {
+		if day.Weekday == d.Weekday() && (day.N == 0 || day.N == fromStart || day.N == fromEnd) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,226 @@
+package rrule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func berlin(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestParseString(t *testing.T) {
+	loc := berlin(t)
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{value: "FREQ=DAILY", want: "FREQ=DAILY"},
+		{value: "RRULE:freq=weekly;interval=2;byday=mo,we", want: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE"},
+		{value: "FREQ=MONTHLY;BYDAY=2TU,-1FR;COUNT=6", want: "FREQ=MONTHLY;COUNT=6;BYDAY=2TU,-1FR"},
+		{value: "FREQ=MONTHLY;BYMONTHDAY=1,-1", want: "FREQ=MONTHLY;BYMONTHDAY=1,-1"},
+		{value: "FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU;WKST=MO", want: "FREQ=YEARLY;BYDAY=-1SU;BYMONTH=3"},
+		{value: "FREQ=DAILY;UNTIL=20240301T120000Z", want: "FREQ=DAILY;UNTIL=20240301T120000Z"},
+		{value: "FREQ=DAILY;UNTIL=20240301T120000", want: "FREQ=DAILY;UNTIL=20240301T110000Z"},
+		{value: "FREQ=DAILY;UNTIL=20240301", want: "FREQ=DAILY;UNTIL=20240301T225959Z"},
+	}
+	for _, tt := range tests {
+		rule, err := Parse(tt.value, loc)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.value, err)
+			continue
+		}
+		if got := rule.String(); got != tt.want {
+			t.Errorf("Parse(%q).String() = %q, want %q", tt.value, got, tt.want)
+		}
+		again, err := Parse(rule.String(), loc)
+		if err != nil || again.String() != rule.String() {
+			t.Errorf("Parse(%q) does not read its own String: %q, %v", tt.value, again.String(), err)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"RRULE:",
+		"COUNT=3",
+		"FREQ=HOURLY",
+		"FREQ=DAILY;COUNT",
+		"FREQ=DAILY;COUNT=0",
+		"FREQ=DAILY;INTERVAL=-1",
+		"FREQ=DAILY;COUNT=2;UNTIL=20240301T000000Z",
+		"FREQ=DAILY;UNTIL=tomorrow",
+		"FREQ=DAILY;BYDAY=XX",
+		"FREQ=WEEKLY;BYDAY=1MO",
+		"FREQ=MONTHLY;BYDAY=6MO",
+		"FREQ=MONTHLY;BYMONTHDAY=0",
+		"FREQ=MONTHLY;BYMONTHDAY=32",
+		"FREQ=YEARLY;BYMONTH=13",
+		"FREQ=YEARLY;BYDAY=MO",
+		"FREQ=WEEKLY;BYMONTHDAY=1",
+		"FREQ=WEEKLY;WKST=SU",
+		"FREQ=DAILY;BYSETPOS=1",
+	} {
+		if rule, err := Parse(value, time.UTC); err == nil {
+			t.Errorf("Parse(%q) = %v, want an error", value, rule)
+		}
+	}
+}
+
+func TestBetween(t *testing.T) {
+	loc := berlin(t)
+	at := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name     string
+		rule     string
+		start    time.Time
+		from, to time.Time // default to start and a year later
+		want     []string
+	}{
+		{
+			name:  "daily count",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: at(2024, 3, 1, 9, 0),
+			want:  []string{"2024-03-01 09:00 +0100", "2024-03-02 09:00 +0100", "2024-03-03 09:00 +0100"},
+		},
+		{
+			name:  "count is counted from the start, not from the window",
+			rule:  "FREQ=DAILY;COUNT=5",
+			start: at(2024, 3, 1, 9, 0),
+			from:  at(2024, 3, 4, 0, 0),
+			to:    at(2024, 3, 20, 0, 0),
+			want:  []string{"2024-03-04 09:00 +0100", "2024-03-05 09:00 +0100"},
+		},
+		{
+			name:  "window without count",
+			rule:  "FREQ=DAILY;INTERVAL=2",
+			start: at(2024, 3, 1, 9, 0),
+			from:  at(2024, 3, 4, 0, 0),
+			to:    at(2024, 3, 9, 9, 0),
+			want:  []string{"2024-03-05 09:00 +0100", "2024-03-07 09:00 +0100"},
+		},
+		{
+			name:  "weekly byday from a matching start",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,WE,FR;COUNT=5",
+			start: at(2024, 3, 1, 7, 30),
+			want: []string{
+				"2024-03-01 07:30 +0100", "2024-03-04 07:30 +0100", "2024-03-06 07:30 +0100",
+				"2024-03-08 07:30 +0100", "2024-03-11 07:30 +0100",
+			},
+		},
+		{
+			name:  "weekly byday skips days before the start",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,TH;COUNT=3",
+			start: at(2024, 3, 6, 18, 0), // a Wednesday
+			want:  []string{"2024-03-07 18:00 +0100", "2024-03-11 18:00 +0100", "2024-03-14 18:00 +0100"},
+		},
+		{
+			name:  "biweekly until, inclusive",
+			rule:  "FREQ=WEEKLY;INTERVAL=2;UNTIL=20240329T080000Z",
+			start: at(2024, 3, 1, 9, 0),
+			want:  []string{"2024-03-01 09:00 +0100", "2024-03-15 09:00 +0100", "2024-03-29 09:00 +0100"},
+		},
+		{
+			name:  "until date includes the whole day",
+			rule:  "FREQ=DAILY;UNTIL=20240315",
+			start: at(2024, 3, 13, 22, 0),
+			want:  []string{"2024-03-13 22:00 +0100", "2024-03-14 22:00 +0100", "2024-03-15 22:00 +0100"},
+		},
+		{
+			name:  "monthly on the 31st skips shorter months",
+			rule:  "FREQ=MONTHLY;COUNT=4",
+			start: at(2024, 1, 31, 12, 0),
+			want:  []string{"2024-01-31 12:00 +0100", "2024-03-31 12:00 +0200", "2024-05-31 12:00 +0200", "2024-07-31 12:00 +0200"},
+		},
+		{
+			name:  "monthly on the last day",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1;COUNT=3",
+			start: at(2024, 1, 31, 12, 0),
+			want:  []string{"2024-01-31 12:00 +0100", "2024-02-29 12:00 +0100", "2024-03-31 12:00 +0200"},
+		},
+		{
+			name:  "monthly last friday",
+			rule:  "FREQ=MONTHLY;BYDAY=-1FR;COUNT=3",
+			start: at(2024, 1, 26, 17, 0),
+			want:  []string{"2024-01-26 17:00 +0100", "2024-02-23 17:00 +0100", "2024-03-29 17:00 +0100"},
+		},
+		{
+			name:  "monthly second tuesday",
+			rule:  "FREQ=MONTHLY;BYDAY=2TU;COUNT=3",
+			start: at(2024, 1, 1, 10, 0),
+			want:  []string{"2024-01-09 10:00 +0100", "2024-02-13 10:00 +0100", "2024-03-12 10:00 +0100"},
+		},
+		{
+			name:  "yearly on leap day",
+			rule:  "FREQ=YEARLY;COUNT=2",
+			start: at(2024, 2, 29, 8, 0),
+			to:    at(2030, 1, 1, 0, 0),
+			want:  []string{"2024-02-29 08:00 +0100", "2028-02-29 08:00 +0100"},
+		},
+		{
+			name:  "yearly on the day of the spring DST change",
+			rule:  "FREQ=YEARLY;BYMONTH=3;BYDAY=-1SU;COUNT=3",
+			start: at(2024, 3, 31, 9, 0),
+			to:    at(2030, 1, 1, 0, 0),
+			want:  []string{"2024-03-31 09:00 +0200", "2025-03-30 09:00 +0200", "2026-03-29 09:00 +0200"},
+		},
+		{
+			name:  "daily across the spring DST change keeps the wall clock",
+			rule:  "FREQ=DAILY;COUNT=3",
+			start: at(2024, 3, 30, 9, 0),
+			want:  []string{"2024-03-30 09:00 +0100", "2024-03-31 09:00 +0200", "2024-04-01 09:00 +0200"},
+		},
+		{
+			name:  "weekly across the autumn DST change keeps the wall clock",
+			rule:  "FREQ=WEEKLY;COUNT=3",
+			start: at(2024, 10, 20, 23, 30),
+			want:  []string{"2024-10-20 23:30 +0200", "2024-10-27 23:30 +0100", "2024-11-03 23:30 +0100"},
+		},
+		{
+			name:  "daily in a zone without DST",
+			rule:  "FREQ=DAILY;COUNT=2",
+			start: time.Date(2024, 3, 30, 9, 0, 0, 0, time.UTC),
+			want:  []string{"2024-03-30 09:00 +0000", "2024-03-31 09:00 +0000"},
+		},
+		{
+			name:  "window before the start",
+			rule:  "FREQ=DAILY",
+			start: at(2024, 3, 1, 9, 0),
+			from:  at(2024, 2, 1, 0, 0),
+			to:    at(2024, 3, 1, 9, 0),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := Parse(tt.rule, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			from, to := tt.from, tt.to
+			if from.IsZero() {
+				from = tt.start
+			}
+			if to.IsZero() {
+				to = tt.start.AddDate(1, 0, 0)
+			}
+			var got []string
+			for _, occurrence := range rule.Between(tt.start, from, to) {
+				got = append(got, occurrence.Format("2006-01-02 15:04 -0700"))
+			}
+			if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+				t.Errorf("got  %v\nwant %v", got, tt.want)
+			}
+		})
+	}
+}
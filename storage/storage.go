@@ -0,0 +1,19 @@
+package storage
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrNotFound is returned when no object is stored under a key
+var ErrNotFound = errors.New("stored object not found")
+
+// Storage keeps binary objects, such as attachment contents, under opaque keys
+type Storage interface {
+	// Create returns a writer for a new object. The object is complete once the writer is closed.
+	Create(key string) (io.WriteCloser, error)
+	// Open returns a reader for the object stored under key
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes the object stored under key. Deleting a missing object is not an error.
+	Delete(key string) error
+}
@@ -0,0 +1,54 @@
+package types
+
+import "time"
+
+// Batch operations
+const (
+	BatchCreate = "create"
+	BatchUpdate = "update"
+	BatchDelete = "delete"
+)
+
+// BatchActivity holds the fields of an activity created or updated in a batch, as sent to
+// POST /activities and PUT /activities/:id
+type BatchActivity struct {
+	StartTime   time.Time  `json:"start_time" binding:"required"`
+	EndTime     *time.Time `json:"end_time"` // may be omitted to keep a running activity running
+	Description string     `json:"description" binding:"required"`
+	Notes       string     `json:"notes"`
+	Mood        *int       `json:"mood" binding:"omitempty,min=1,max=5"`
+	Energy      *int       `json:"energy" binding:"omitempty,min=1,max=5"`
+	CategoryID  uint       `json:"category_id" binding:"required"`
+	Tags        *[]string  `json:"tags"` // omit to keep the current tags on update
+}
+
+// BatchOperation is one create, update or delete of a batch
+type BatchOperation struct {
+	Op       string         `json:"op"`       // create, update or delete
+	ID       uint           `json:"id"`       // activity to update or delete
+	Activity *BatchActivity `json:"activity"` // for create and update
+}
+
+// BatchRequest is a list of operations applied in order in one transaction
+type BatchRequest struct {
+	Operations []BatchOperation `json:"operations" binding:"required,min=1,max=100"`
+	Atomic     bool             `json:"atomic"` // roll every operation back when one fails
+}
+
+// BatchItemResult is the outcome of one operation of a batch
+type BatchItemResult struct {
+	Index      int        `json:"index"`
+	Op         string     `json:"op"`
+	Status     int        `json:"status"` // HTTP status the operation would have had on its own
+	Applied    bool       `json:"applied"`
+	ActivityID uint       `json:"activity_id,omitempty"`
+	Error      *ErrorInfo `json:"error,omitempty"`
+}
+
+// BatchResult lists the outcome of every operation of a batch
+type BatchResult struct {
+	Applied    int               `json:"applied"`
+	Failed     int               `json:"failed"`
+	RolledBack bool              `json:"rolled_back"`
+	Results    []BatchItemResult `json:"results"`
+}
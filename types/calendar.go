@@ -0,0 +1,10 @@
+package types
+
+import "time"
+
+// CalendarFeedInfo describes the calendar feed of a user. The URL holds the
+// secret token and is only returned when the feed is created.
+type CalendarFeedInfo struct {
+	URL       string    `json:"url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
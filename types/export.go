@@ -0,0 +1,16 @@
+package types
+
+// ExportedActivity is one activity in a data export. Times are RFC 3339 in the user's time zone.
+type ExportedActivity struct {
+	ID              uint     `json:"id"`
+	Date            string   `json:"date"` // YYYY-MM-DD
+	StartTime       string   `json:"start_time"`
+	EndTime         *string  `json:"end_time"` // null while running
+	DurationSeconds int      `json:"duration_seconds"`
+	Category        string   `json:"category"`
+	Description     string   `json:"description"`
+	Notes           string   `json:"notes"`
+	Tags            []string `json:"tags"`
+	Mood            *int     `json:"mood"`
+	Energy          *int     `json:"energy"`
+}
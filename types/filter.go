@@ -1,7 +1,131 @@
 package types
 
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
 type ActivityFilter struct {
-	CategoryID *uint `form:"category_id"`
-	StartDate  *string `form:"start_date"` // YYYY-MM-DD
-	EndDate    *string `form:"end_date"` // YYYY-MM-DD
-}
\ No newline at end of file
+	CategoryID    *uint   `form:"category_id"`
+	CategoryIDs   *string `form:"category_ids"`                           // comma separated category IDs
+	StartDate     *string `form:"start_date"`                             // YYYY-MM-DD, in the user's time zone
+	EndDate       *string `form:"end_date"`                               // YYYY-MM-DD, in the user's time zone
+	MinDuration   *int    `form:"min_duration" binding:"omitempty,min=0"` // in second
+	MaxDuration   *int    `form:"max_duration" binding:"omitempty,min=0"` // in second
+	StartedAfter  *string `form:"started_after"`                          // HH:MM, in the user's time zone
+	StartedBefore *string `form:"started_before"`                         // HH:MM, in the user's time zone
+	Tags          *string `form:"tags"`                                   // comma separated tag names
+	TagMatch      string  `form:"tag_match,default=any" binding:"oneof=any all"`
+	Query         *string `form:"q"`       // keywords matched against description and notes
+	Pending       *bool   `form:"pending"` // activities from recurring templates awaiting confirmation
+
+	// IncludePending keeps pending activities when Pending is not given. Only the activity
+	// list shows them by default, everything else counts confirmed activities only.
+	IncludePending bool `form:"-"`
+}
+
+// ActivitySort represents the sorting query parameters of activity listings
+type ActivitySort struct {
+	Sort  string `form:"sort" binding:"omitempty,oneof=created_at start_time duration category"`
+	Order string `form:"order,default=desc" binding:"oneof=asc desc"`
+}
+
+// Key returns the sort key of the listing. Offset pages default to the creation
+// time and cursor pages to the start time.
+func (s ActivitySort) Key(useCursor bool) string {
+	if s.Sort != "" {
+		return s.Sort
+	}
+	if useCursor {
+		return "start_time"
+	}
+	return "created_at"
+}
+
+// Validate checks the filter values that binding cannot check on its own
+func (f ActivityFilter) Validate() error {
+	if _, err := f.CategoryIDList(); err != nil {
+		return err
+	}
+	if f.StartDate != nil {
+		if _, err := ParseDay(*f.StartDate, time.UTC); err != nil {
+			return fmt.Errorf("start_date must be formatted as YYYY-MM-DD")
+		}
+	}
+	if f.EndDate != nil {
+		if _, err := ParseDay(*f.EndDate, time.UTC); err != nil {
+			return fmt.Errorf("end_date must be formatted as YYYY-MM-DD")
+		}
+	}
+	if f.StartDate != nil && f.EndDate != nil && *f.StartDate > *f.EndDate {
+		return errors.New("start_date must not be after end_date")
+	}
+	if f.MinDuration != nil && f.MaxDuration != nil && *f.MinDuration > *f.MaxDuration {
+		return errors.New("min_duration must not be greater than max_duration")
+	}
+	if f.StartedAfter != nil {
+		if _, err := ParseTimeOfDay(*f.StartedAfter); err != nil {
+			return fmt.Errorf("started_after must be formatted as HH:MM")
+		}
+	}
+	if f.StartedBefore != nil {
+		if _, err := ParseTimeOfDay(*f.StartedBefore); err != nil {
+			return fmt.Errorf("started_before must be formatted as HH:MM")
+		}
+	}
+	return nil
+}
+
+// CategoryIDList returns the category IDs of category_id and category_ids combined
+func (f ActivityFilter) CategoryIDList() ([]uint, error) {
+	var ids []uint
+	if f.CategoryID != nil {
+		ids = append(ids, *f.CategoryID)
+	}
+	if f.CategoryIDs == nil {
+		return ids, nil
+	}
+	for _, value := range strings.Split(*f.CategoryIDs, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(value, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("category_ids must be a comma separated list of IDs, got %q", value)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// TagNames returns the tag names of the filter, lowercased like stored tags
+func (f ActivityFilter) TagNames() []string {
+	if f.Tags == nil {
+		return nil
+	}
+	var names []string
+	for _, name := range strings.Split(*f.Tags, ",") {
+		if name = strings.ToLower(strings.TrimSpace(name)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// ParseDay returns the start of a YYYY-MM-DD day in loc
+func ParseDay(value string, loc *time.Location) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", value, loc)
+}
+
+// ParseTimeOfDay validates a HH:MM time of day and returns it as HH:MM:00
+func ParseTimeOfDay(value string) (string, error) {
+	parsed, err := time.Parse("15:04", value)
+	if err != nil {
+		return "", err
+	}
+	return parsed.Format("15:04:05"), nil
+}
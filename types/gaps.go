@@ -0,0 +1,34 @@
+package types
+
+import "time"
+
+// Gap is an interval of a day without any activity logged
+type Gap struct {
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Seconds   int64     `json:"seconds"`
+}
+
+// DayGaps lists the untracked intervals of one day in the user's time zone.
+// The part of today that is still ahead does not count as untracked.
+type DayGaps struct {
+	Date             string `json:"date"` // YYYY-MM-DD
+	TrackedSeconds   int64  `json:"tracked_seconds"`
+	UntrackedSeconds int64  `json:"untracked_seconds"`
+	Gaps             []Gap  `json:"gaps"`
+}
+
+// GapFill is an activity to create in an untracked interval
+type GapFill struct {
+	StartTime   time.Time `json:"start_time" binding:"required"`
+	EndTime     time.Time `json:"end_time" binding:"required"`
+	CategoryID  uint      `json:"category_id" binding:"required"`
+	Description string    `json:"description"`
+	Notes       string    `json:"notes"`
+	Tags        []string  `json:"tags"`
+}
+
+// FillGapsRequest creates activities for several untracked intervals at once
+type FillGapsRequest struct {
+	Gaps []GapFill `json:"gaps" binding:"required,min=1,max=100,dive"`
+}
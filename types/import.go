@@ -0,0 +1,100 @@
+package types
+
+// ImportMapping names the CSV columns holding each activity field. Unset fields
+// default to the column names of the CSV export, so exported files import as they are.
+type ImportMapping struct {
+	StartTime    string `json:"start_time"`
+	EndTime      string `json:"end_time"` // either end_time or duration is needed
+	Duration     string `json:"duration"` // seconds or H:MM[:SS]
+	Description  string `json:"description"`
+	Category     string `json:"category"` // category name, matched case-insensitively
+	Notes        string `json:"notes"`
+	Tags         string `json:"tags"`
+	Mood         string `json:"mood"`
+	Energy       string `json:"energy"`
+	TimeFormat   string `json:"time_format"`   // Go layout, e.g. 02/01/2006 15:04. Defaults to RFC 3339 and YYYY-MM-DD HH:MM[:SS]
+	TagSeparator string `json:"tag_separator"` // defaults to ;
+}
+
+// WithDefaults fills the unset columns with the CSV export column names
+func (m ImportMapping) WithDefaults() ImportMapping {
+	defaults := map[*string]string{
+		&m.StartTime:    "start_time",
+		&m.Description:  "description",
+		&m.Category:     "category",
+		&m.Notes:        "notes",
+		&m.Tags:         "tags",
+		&m.Mood:         "mood",
+		&m.Energy:       "energy",
+		&m.TagSeparator: ";",
+	}
+	for field, value := range defaults {
+		if *field == "" {
+			*field = value
+		}
+	}
+	if m.EndTime == "" && m.Duration == "" {
+		m.EndTime = "end_time"
+	}
+	return m
+}
+
+// ImportRowError describes why one row of an import was rejected
+type ImportRowError struct {
+	Row     int    `json:"row"` // line in the file, the header being line 1
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+// ImportResult summarizes an import or a dry run of it
+type ImportResult struct {
+	DryRun      bool             `json:"dry_run"`
+	TotalRows   int              `json:"total_rows"`
+	ValidRows   int              `json:"valid_rows"`
+	Imported    int              `json:"imported"`
+	Skipped     int              `json:"skipped"` // rows already imported from the same file before
+	Errors      []ImportRowError `json:"errors"`
+	ActivityIDs []uint           `json:"activity_ids,omitempty"`
+}
+
+// TrackerImportMapping maps the projects or tags of a Toggl or Clockify export onto categories
+type TrackerImportMapping struct {
+	MapBy             string          `json:"map_by"`     // project (default) or tag
+	Categories        map[string]uint `json:"categories"` // project or tag name to category ID, "" stands for entries without a project
+	DefaultCategoryID uint            `json:"default_category_id"`
+}
+
+// TrackerMappingEntry is a project or tag found in a time tracker export
+type TrackerMappingEntry struct {
+	Name                string `json:"name"` // empty for entries without a project
+	Entries             int    `json:"entries"`
+	Duration            int    `json:"duration"`              // in seconds
+	SuggestedCategoryID *uint  `json:"suggested_category_id"` // category with the same name, if any
+}
+
+// TrackerImportPreview lists what a time tracker export holds, so the user can confirm
+// the category mapping before importing it
+type TrackerImportPreview struct {
+	Source     string                `json:"source"`
+	Format     string                `json:"format"`
+	Entries    int                   `json:"entries"`
+	Duplicates int                   `json:"duplicates"` // entries already imported
+	Projects   []TrackerMappingEntry `json:"projects"`
+	Tags       []TrackerMappingEntry `json:"tags"`
+	Errors     []ImportRowError      `json:"errors"`
+}
+
+// ICSCategoryRule puts events whose summary contains the keyword into a category
+type ICSCategoryRule struct {
+	Keyword    string `json:"keyword"` // matched case-insensitively
+	CategoryID uint   `json:"category_id"`
+}
+
+// ICSImportOptions chooses which events of a calendar are imported and into which categories
+type ICSImportOptions struct {
+	Rules             []ICSCategoryRule `json:"rules"` // the first matching rule wins
+	DefaultCategoryID uint              `json:"default_category_id"`
+	From              string            `json:"from"` // YYYY-MM-DD, defaults to a year before today
+	To                string            `json:"to"`   // YYYY-MM-DD, inclusive, defaults to today
+	IncludeAllDay     bool              `json:"include_all_day"`
+}
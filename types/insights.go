@@ -0,0 +1,28 @@
+package types
+
+// RatingComparison compares the average rating of days with enough time on a
+// category against the other rated days. Averages are null without any days.
+type RatingComparison struct {
+	AverageWith    *float64 `json:"average_with"`
+	AverageWithout *float64 `json:"average_without"`
+	Difference     *float64 `json:"difference"` // average_with minus average_without
+}
+
+// CategoryMoodInsight relates the time spent on one category to mood and energy
+type CategoryMoodInsight struct {
+	CategoryID   uint             `json:"category_id"`
+	CategoryName string           `json:"category_name"`
+	DaysWith     int              `json:"days_with"`    // rated days with at least min_minutes on the category
+	DaysWithout  int              `json:"days_without"` // other rated days
+	Mood         RatingComparison `json:"mood"`
+	Energy       RatingComparison `json:"energy"`
+}
+
+// MoodInsights relates category time to the ratings of each day in a date range
+type MoodInsights struct {
+	StartDate  string                `json:"start_date"` // YYYY-MM-DD
+	EndDate    string                `json:"end_date"`   // YYYY-MM-DD
+	MinMinutes int                   `json:"min_minutes"`
+	RatedDays  int                   `json:"rated_days"`
+	Categories []CategoryMoodInsight `json:"categories"`
+}
@@ -0,0 +1,13 @@
+package types
+
+// Overlap policies for activities that overlap other activities of the same user
+const (
+	OverlapReject = "reject" // refuse the change
+	OverlapTrim   = "trim"   // shorten the neighbouring activities
+	OverlapSplit  = "split"  // like trim, but cut an enclosing activity in two
+)
+
+// OverlapQuery represents the query parameter selecting the overlap policy
+type OverlapQuery struct {
+	Overlap string `form:"overlap,default=reject" binding:"oneof=reject trim split"`
+}
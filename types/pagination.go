@@ -1,5 +1,11 @@
 package types
 
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
 // PaginationQuery represents the query parameters for pagination
 type PaginationQuery struct {
 	Page     int `form:"page,default=1" binding:"min=1"`
@@ -8,17 +14,17 @@ type PaginationQuery struct {
 
 // PaginationResponse represents the pagination metadata in responses
 type PaginationResponse struct {
-	CurrentPage  int   `json:"current_page"`
-	PageSize     int   `json:"page_size"`
-	TotalItems   int64 `json:"total_items"`
-	TotalPages   int   `json:"total_pages"`
+	CurrentPage int   `json:"current_page"`
+	PageSize    int   `json:"page_size"`
+	TotalItems  int64 `json:"total_items"`
+	TotalPages  int   `json:"total_pages"`
 	HasMore     bool  `json:"has_more"`
 }
 
 // NewPaginationResponse creates a new pagination response
 func NewPaginationResponse(currentPage, pageSize int, totalItems int64) PaginationResponse {
 	totalPages := int((totalItems + int64(pageSize) - 1) / int64(pageSize))
-	
+
 	return PaginationResponse{
 		CurrentPage: currentPage,
 		PageSize:    pageSize,
@@ -27,3 +33,52 @@ func NewPaginationResponse(currentPage, pageSize int, totalItems int64) Paginati
 		HasMore:     currentPage < totalPages,
 	}
 }
+
+// CursorQuery represents the query parameters for keyset (cursor) pagination
+type CursorQuery struct {
+	Cursor       string `form:"cursor"`                                                // opaque cursor from the previous page
+	Paginate     string `form:"paginate,default=offset" binding:"oneof=offset cursor"` // cursor is implied when a cursor is given
+	IncludeTotal *bool  `form:"include_total"`                                         // counting is skipped by default for cursors
+}
+
+// CursorResponse represents the keyset pagination metadata in responses
+type CursorResponse struct {
+	PageSize   int    `json:"page_size"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more"`
+	TotalItems *int64 `json:"total_items,omitempty"`
+}
+
+// UseCursor reports whether the request asks for keyset pagination
+func (q CursorQuery) UseCursor() bool {
+	return q.Paginate == "cursor" || q.Cursor != ""
+}
+
+// WantsTotal reports whether the total item count should be computed
+func (q CursorQuery) WantsTotal() bool {
+	if q.IncludeTotal != nil {
+		return *q.IncludeTotal
+	}
+	return !q.UseCursor()
+}
+
+// EncodeCursor turns a position into an opaque cursor string
+func EncodeCursor(position interface{}) (string, error) {
+	raw, err := json.Marshal(position)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// DecodeCursor reads a cursor string created by EncodeCursor into position
+func DecodeCursor(cursor string, position interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return errors.New("invalid cursor")
+	}
+	if err := json.Unmarshal(raw, position); err != nil {
+		return errors.New("invalid cursor")
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package types
+
+import "time"
+
+// ParseRequest is the free text of a quick entry, e.g. "gym 7-8:30am"
+type ParseRequest struct {
+	Text string `json:"text" binding:"required,max=200"`
+}
+
+// ParseQuery holds the options of a quick entry
+type ParseQuery struct {
+	OverlapQuery
+	Commit bool `form:"commit"` // save the proposal as an activity
+}
+
+// ParsedActivity is an activity proposed from a quick entry
+type ParsedActivity struct {
+	Description  string    `json:"description"`
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
+	CategoryID   uint      `json:"category_id"`
+	CategoryName string    `json:"category_name"`
+	Confidence   float64   `json:"confidence"`        // 0 to 1
+	Assumed      []string  `json:"assumed,omitempty"` // what was not in the text and had to be guessed
+}
+
+// ParseResult is the best reading of a quick entry and the next best ones
+type ParseResult struct {
+	Text         string           `json:"text"`
+	Proposal     ParsedActivity   `json:"proposal"`
+	Alternatives []ParsedActivity `json:"alternatives"`
+	ActivityID   *uint            `json:"activity_id,omitempty"` // set when the proposal was saved
+}
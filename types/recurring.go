@@ -0,0 +1,8 @@
+package types
+
+// RecurringGenerateResult summarizes a run turning due occurrences of recurring templates into activities
+type RecurringGenerateResult struct {
+	Created     int    `json:"created"`
+	Skipped     int    `json:"skipped"` // occurrences overlapping an existing activity
+	ActivityIDs []uint `json:"activity_ids"`
+}
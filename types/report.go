@@ -0,0 +1,24 @@
+package types
+
+// CategoryTotal is the time spent on one category within a report period
+type CategoryTotal struct {
+	CategoryID   uint   `json:"category_id"`
+	CategoryName string `json:"category_name"`
+	TotalSeconds int64  `json:"total_seconds"`
+}
+
+// TagTotal is the time spent on activities with one tag within a report period.
+// Untagged time has no tag ID.
+type TagTotal struct {
+	TagID        *uint  `json:"tag_id"`
+	TagName      string `json:"tag_name"`
+	TotalSeconds int64  `json:"total_seconds"`
+}
+
+// ReportPeriod is the time spent per category during one day or week
+type ReportPeriod struct {
+	PeriodStart  string          `json:"period_start"` // YYYY-MM-DD, Monday for weekly reports
+	TotalSeconds int64           `json:"total_seconds"`
+	Categories   []CategoryTotal `json:"categories"`
+	Tags         []TagTotal      `json:"tags,omitempty"` // only with breakdown=tag
+}
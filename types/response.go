@@ -2,18 +2,20 @@ package types
 
 // Response is the standard API response structure
 type Response struct {
-	Success    bool               `json:"success"`
-	Message    string             `json:"message"`
-	Data       interface{}        `json:"data,omitempty"`
+	Success    bool                `json:"success"`
+	Message    string              `json:"message"`
+	Data       interface{}         `json:"data,omitempty"`
 	Pagination *PaginationResponse `json:"pagination,omitempty"`
-	Error      *ErrorInfo         `json:"error,omitempty"`
+	Cursor     *CursorResponse     `json:"cursor,omitempty"`
+	Error      *ErrorInfo          `json:"error,omitempty"`
 }
 
 // ErrorInfo contains detailed error information
 type ErrorInfo struct {
-	Code    string `json:"code,omitempty"`
-	Message string `json:"message"`
-	Detail  string `json:"detail,omitempty"`
+	Code    string      `json:"code,omitempty"`
+	Message string      `json:"message"`
+	Detail  string      `json:"detail,omitempty"`
+	Meta    interface{} `json:"meta,omitempty"`
 }
 
 // NewSuccessResponse creates a new success response
@@ -26,6 +28,16 @@ func NewSuccessResponse(message string, data interface{}, pagination *Pagination
 	}
 }
 
+// NewCursorSuccessResponse creates a new success response with keyset pagination
+func NewCursorSuccessResponse(message string, data interface{}, cursor *CursorResponse) Response {
+	return Response{
+		Success: true,
+		Message: message,
+		Data:    data,
+		Cursor:  cursor,
+	}
+}
+
 // NewErrorResponse creates a new error response
 func NewErrorResponse(code, message, detail string) Response {
 	return Response{
@@ -38,3 +50,10 @@ func NewErrorResponse(code, message, detail string) Response {
 		},
 	}
 }
+
+// NewErrorResponseWithMeta creates a new error response carrying structured details
+func NewErrorResponseWithMeta(code, message, detail string, meta interface{}) Response {
+	response := NewErrorResponse(code, message, detail)
+	response.Error.Meta = meta
+	return response
+}
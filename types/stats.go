@@ -0,0 +1,25 @@
+package types
+
+// Streak describes consecutive days or weeks meeting a condition
+type Streak struct {
+	Current      int     `json:"current"`
+	Longest      int     `json:"longest"`
+	CurrentStart *string `json:"current_start"` // YYYY-MM-DD, null without a current streak
+}
+
+// GoalStreak is the streak of periods in which a goal was met
+type GoalStreak struct {
+	GoalID uint   `json:"goal_id"`
+	Kind   string `json:"kind"`
+	Period string `json:"period"`
+	Streak
+}
+
+// CategoryStreaks holds the streaks of one category
+type CategoryStreaks struct {
+	CategoryID   uint         `json:"category_id"`
+	CategoryName string       `json:"category_name"`
+	Daily        Streak       `json:"daily"`  // consecutive days with time logged
+	Weekly       Streak       `json:"weekly"` // consecutive weeks with time logged
+	Goals        []GoalStreak `json:"goals"`
+}